@@ -0,0 +1,47 @@
+package common
+
+import (
+	"github.com/elimity-com/scim"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// AttributeValue returns the value at an attribute path of a resource, such as "userName", "name.givenName",
+// `emails[type eq "work"].value` or "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter".
+// If a value filter matches several values, the first one is used. Nil is returned if the path has no value.
+func AttributeValue(attributes scim.ResourceAttributes, path string) (any, error) {
+	if value, ok := attributes[path]; ok {
+		return value, nil
+	}
+
+	p, err := filter.ParsePath([]byte(path))
+	if err != nil {
+		return nil, err
+	}
+
+	target := resolveTarget(attributes, &p, false, nil)
+	if target == nil {
+		return nil, nil
+	}
+
+	value := target.value()
+
+	if target.filter != nil {
+		values, matches, err := matchValues(target)
+		if err != nil || len(matches) == 0 {
+			return nil, err
+		}
+
+		value = values[matches[0]]
+	}
+
+	if target.sub == "" {
+		return value, nil
+	}
+
+	complexValue, ok := value.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	return complexValue[findKey(complexValue, target.sub)], nil
+}
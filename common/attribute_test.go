@@ -0,0 +1,37 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeValue(t *testing.T) {
+	const acme = "urn:example:params:scim:schemas:extension:acme:2.0:User"
+
+	attributes := rickAttributes()
+	attributes[acme] = map[string]any{"costCode": "C-137"}
+
+	tests := []struct {
+		path     string
+		expected any
+	}{
+		{path: "userName", expected: "rick"},
+		{path: "NAME.givenName", expected: "Rick"},
+		{path: `emails[type eq "work"].value`, expected: "rick@the-citadel.com"},
+		{path: `emails[type eq "home"].value`, expected: nil},
+		{path: acme + ":costCode", expected: "C-137"},
+		{path: "urn:example:params:scim:schemas:extension:other:2.0:User:costCode", expected: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert := require.New(t)
+
+			value, err := common.AttributeValue(attributes, tc.path)
+			assert.NoError(err)
+			assert.Equal(tc.expected, value)
+		})
+	}
+}
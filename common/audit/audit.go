@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+)
+
+// Directory write actions.
+const (
+	ActionSetObject      = "set_object"
+	ActionDeleteObject   = "delete_object"
+	ActionSetRelation    = "set_relation"
+	ActionDeleteRelation = "delete_relation"
+)
+
+// RedactedValue replaces the value of redacted attributes and properties.
+const RedactedValue = "[REDACTED]"
+
+// Entry is the audit record of a SCIM operation that changes a resource.
+type Entry struct {
+	Time         time.Time          `json:"time"`
+	Tenant       string             `json:"tenant,omitempty"`
+	Caller       string             `json:"caller,omitempty"`
+	Operation    string             `json:"operation"`
+	ResourceType string             `json:"resource_type"`
+	ResourceID   string             `json:"resource_id,omitempty"`
+	Changes      map[string]*Change `json:"changes,omitempty"`
+	Writes       []*Write           `json:"writes,omitempty"`
+	RolledBack   bool               `json:"rolled_back,omitempty"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// Change is the value of a resource attribute before and after an operation. Attributes that are added have no
+// value before, and attributes that are removed have no value after.
+type Change struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// Write is a directory object or relation written or deleted by an operation. Deleted objects only carry their
+// type and id.
+type Write struct {
+	Action   string    `json:"action"`
+	Object   *Object   `json:"object,omitempty"`
+	Relation *Relation `json:"relation,omitempty"`
+}
+
+type Object struct {
+	Type        string         `json:"type"`
+	ID          string         `json:"id"`
+	DisplayName string         `json:"display_name,omitempty"`
+	Properties  map[string]any `json:"properties,omitempty"`
+}
+
+type Relation struct {
+	ObjectType      string `json:"object_type"`
+	ObjectID        string `json:"object_id"`
+	Relation        string `json:"relation"`
+	SubjectType     string `json:"subject_type"`
+	SubjectID       string `json:"subject_id"`
+	SubjectRelation string `json:"subject_relation,omitempty"`
+}
+
+// Sink stores audit entries, such as in a file or a log pipeline.
+type Sink interface {
+	Write(ctx context.Context, entry *Entry) error
+}
+
+// Recorder collects the directory writes made while performing an operation.
+type Recorder struct {
+	mu         sync.Mutex
+	writes     []*Write
+	rolledBack bool
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context that records the directory writes made with it in the returned recorder.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// Writes returns the recorded directory writes, in the order they were made.
+func (r *Recorder) Writes() []*Write {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.writes
+}
+
+// RolledBack reports whether the recorded writes were undone after the operation failed.
+func (r *Recorder) RolledBack() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rolledBack
+}
+
+func (r *Recorder) record(write *Write) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes = append(r.writes, write)
+}
+
+func recorderFromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// RecordObject records that an object was written or deleted, if the context has a recorder.
+func RecordObject(ctx context.Context, action string, object *dsc.Object) {
+	r := recorderFromContext(ctx)
+	if r == nil {
+		return
+	}
+
+	r.record(&Write{Action: action, Object: &Object{
+		Type:        object.GetType(),
+		ID:          object.GetId(),
+		DisplayName: object.GetDisplayName(),
+		Properties:  object.GetProperties().AsMap(),
+	}})
+}
+
+// RecordRelation records that a relation was written or deleted, if the context has a recorder.
+func RecordRelation(ctx context.Context, action string, relation *dsc.Relation) {
+	r := recorderFromContext(ctx)
+	if r == nil {
+		return
+	}
+
+	r.record(&Write{Action: action, Relation: &Relation{
+		ObjectType:      relation.GetObjectType(),
+		ObjectID:        relation.GetObjectId(),
+		Relation:        relation.GetRelation(),
+		SubjectType:     relation.GetSubjectType(),
+		SubjectID:       relation.GetSubjectId(),
+		SubjectRelation: relation.GetSubjectRelation(),
+	}})
+}
+
+// RecordRollback records that the writes of the operation were undone, if the context has a recorder.
+func RecordRollback(ctx context.Context) {
+	r := recorderFromContext(ctx)
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rolledBack = true
+}
+
+// Redactor replaces the values of sensitive attributes, matched by name at any depth and regardless of case.
+type Redactor struct {
+	names map[string]bool
+}
+
+// NewRedactor returns a redactor of the given attribute names and of "password".
+func NewRedactor(names ...string) *Redactor {
+	r := &Redactor{names: map[string]bool{"password": true}}
+
+	for _, name := range names {
+		r.names[strings.ToLower(name)] = true
+	}
+
+	return r
+}
+
+// Entry redacts the attribute changes and the properties of the written objects of an entry.
+func (r *Redactor) Entry(entry *Entry) {
+	for name, change := range entry.Changes {
+		if r.names[strings.ToLower(name)] {
+			entry.Changes[name] = &Change{Before: redactedValue(change.Before), After: redactedValue(change.After)}
+			continue
+		}
+
+		change.Before = r.Redact(change.Before)
+		change.After = r.Redact(change.After)
+	}
+
+	for _, write := range entry.Writes {
+		if write.Object != nil && write.Object.Properties != nil {
+			write.Object.Properties, _ = r.Redact(write.Object.Properties).(map[string]any)
+		}
+	}
+}
+
+// Redact returns a copy of value in which the values of redacted attributes are replaced.
+func (r *Redactor) Redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+
+		for name, attr := range v {
+			if r.names[strings.ToLower(name)] {
+				redacted[name] = RedactedValue
+			} else {
+				redacted[name] = r.Redact(attr)
+			}
+		}
+
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = r.Redact(item)
+		}
+
+		return redacted
+	case []map[string]any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = r.Redact(item)
+		}
+
+		return redacted
+	default:
+		return value
+	}
+}
+
+func redactedValue(value any) any {
+	if value == nil {
+		return nil
+	}
+
+	return RedactedValue
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -9,31 +11,126 @@ import (
 var ErrInvalidConfig = errors.New("invalid config")
 
 type Config struct {
-	User      *User       `json:"user"`
-	Group     *Group      `json:"group"`
-	Role      *Role       `json:"role"`
-	Relations []*Relation `json:"relations"`
+	User          *User           `json:"user"`
+	Group         *Group          `json:"group"`
+	Role          *Role           `json:"role"`
+	Relations     []*Relation     `json:"relations"`
+	ResourceTypes []*ResourceType `json:"resource_types"`
 }
 
 type User struct {
-	ObjectType         string            `json:"object_type"`
-	IdentityObjectType string            `json:"identity_object_type"`
-	IdentityRelation   string            `json:"identity_relation"`
-	PropertyMapping    map[string]string `json:"property_mapping"`
-	SourceObjectType   string            `json:"source_object_type"`
-	ManagerRelation    string            `json:"manager_relation"`
+	ObjectType         string             `json:"object_type"`
+	IdentityObjectType string             `json:"identity_object_type"`
+	IdentityRelation   string             `json:"identity_relation"`
+	PropertyMapping    map[string]string  `json:"property_mapping"`
+	SourceObjectType   string             `json:"source_object_type"`
+	ManagerRelation    string             `json:"manager_relation"`
+	SchemaExtensions   []*SchemaExtension `json:"schema_extensions"`
 }
 
 type Group struct {
-	ObjectType          string `json:"object_type"`
-	GroupMemberRelation string `json:"group_member_relation"`
-	SourceObjectType    string `json:"source_object_type"`
+	ObjectType          string             `json:"object_type"`
+	GroupMemberRelation string             `json:"group_member_relation"`
+	SourceObjectType    string             `json:"source_object_type"`
+	SchemaExtensions    []*SchemaExtension `json:"schema_extensions"`
 }
 type Role struct {
 	ObjectType   string `json:"object_type"`
 	RoleRelation string `json:"role_relation"`
 }
 
+// SharesGroupRelation reports whether role relations can't be told apart from group member relations, because
+// roles and groups use the same object type and relation.
+func (r *Role) SharesGroupRelation(group *Group) bool {
+	return group != nil && r.ObjectType == group.ObjectType && r.RoleRelation == group.GroupMemberRelation
+}
+
+// ResourceType declares an additional SCIM resource type, such as devices or service accounts. Its resources are
+// stored as source objects and transformed into directory objects by the section of the transform template
+// selected by TemplateSection, which defaults to the lowercase resource type name. Relations names the relations of
+// the directory object that the template owns, which are removed when the template no longer produces them.
+type ResourceType struct {
+	Name             string             `json:"name"`
+	Endpoint         string             `json:"endpoint"`
+	Description      string             `json:"description"`
+	Schema           *Schema            `json:"schema"`
+	SchemaExtensions []*SchemaExtension `json:"schema_extensions"`
+	SourceObjectType string             `json:"source_object_type"`
+	ObjectType       string             `json:"object_type"`
+	TemplateSection  string             `json:"template_section"`
+	Relations        []string           `json:"relations"`
+}
+
+// Section returns the value of .objectType that selects the transform template section of the resource type.
+func (r *ResourceType) Section() string {
+	if r.TemplateSection != "" {
+		return r.TemplateSection
+	}
+
+	return strings.ToLower(r.Name)
+}
+
+// Schema declares the core schema of a resource type.
+type Schema struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Attributes  []*Attribute `json:"attributes"`
+}
+
+// SchemaExtension declares a custom SCIM schema extension of a resource type, such as
+// "urn:ietf:params:scim:schemas:extension:acme:2.0:User".
+type SchemaExtension struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Required    bool         `json:"required"`
+	Attributes  []*Attribute `json:"attributes"`
+}
+
+// Attribute declares an attribute of a schema extension, with the characteristics defined in RFC 7643 section 2.2.
+// Only complex attributes have sub-attributes, and sub-attributes can't be complex.
+type Attribute struct {
+	Name            string       `json:"name"`
+	Type            string       `json:"type"`
+	Description     string       `json:"description"`
+	MultiValued     bool         `json:"multi_valued"`
+	Required        bool         `json:"required"`
+	CaseExact       bool         `json:"case_exact"`
+	Mutability      string       `json:"mutability"`
+	Returned        string       `json:"returned"`
+	Uniqueness      string       `json:"uniqueness"`
+	CanonicalValues []string     `json:"canonical_values"`
+	ReferenceTypes  []string     `json:"reference_types"`
+	SubAttributes   []*Attribute `json:"sub_attributes"`
+}
+
+const (
+	AttributeTypeString    = "string"
+	AttributeTypeBoolean   = "boolean"
+	AttributeTypeDecimal   = "decimal"
+	AttributeTypeInteger   = "integer"
+	AttributeTypeDateTime  = "dateTime"
+	AttributeTypeReference = "reference"
+	AttributeTypeBinary    = "binary"
+	AttributeTypeComplex   = "complex"
+)
+
+var (
+	attributeTypes = []string{
+		"", AttributeTypeString, AttributeTypeBoolean, AttributeTypeDecimal, AttributeTypeInteger,
+		AttributeTypeDateTime, AttributeTypeReference, AttributeTypeBinary, AttributeTypeComplex,
+	}
+	attributeMutabilities = []string{"", "readOnly", "readWrite", "immutable", "writeOnly"}
+	attributeReturned     = []string{"", "always", "never", "default", "request"}
+	attributeUniqueness   = []string{"", "none", "server", "global"}
+	attributeName         = regexp.MustCompile(`^[A-Za-z][\w$-]*$`)
+	resourceTypeName      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+	reservedEndpoints     = []string{
+		"/users", "/groups", "/roles", "/bulk", "/me", "/schemas", "/resourcetypes", "/serviceproviderconfig", "/healthz", "/readyz",
+	}
+)
+
 type Relation struct {
 	SubjectType     string `json:"subject_type"`
 	SubjectID       string `json:"subject_id"`
@@ -43,6 +140,58 @@ type Relation struct {
 	SubjectRelation string `json:"subject_relation"`
 }
 
+// RequiredModel returns the object types that the configuration reads and writes, mapped to the relations of
+// each type that it uses. The directory model must declare them.
+func (cfg *Config) RequiredModel() map[string][]string {
+	model := map[string][]string{}
+
+	require := func(objectType string, relations ...string) {
+		if objectType == "" {
+			return
+		}
+
+		for _, relation := range relations {
+			if relation != "" && !slices.Contains(model[objectType], relation) {
+				model[objectType] = append(model[objectType], relation)
+			}
+		}
+
+		if _, ok := model[objectType]; !ok {
+			model[objectType] = nil
+		}
+	}
+
+	if cfg.User != nil {
+		identityType, identityRelation, _ := strings.Cut(cfg.User.IdentityRelation, "#")
+
+		require(cfg.User.ObjectType, cfg.User.ManagerRelation)
+		require(cfg.User.IdentityObjectType)
+		require(cfg.User.SourceObjectType)
+		require(identityType, identityRelation)
+	}
+
+	if cfg.Group != nil {
+		require(cfg.Group.ObjectType, cfg.Group.GroupMemberRelation)
+		require(cfg.Group.SourceObjectType)
+	}
+
+	if cfg.Role != nil {
+		require(cfg.Role.ObjectType, cfg.Role.RoleRelation)
+	}
+
+	for _, relation := range cfg.Relations {
+		require(relation.ObjectType, relation.Relation)
+		require(relation.SubjectType, relation.SubjectRelation)
+	}
+
+	for _, resourceType := range cfg.ResourceTypes {
+		require(resourceType.ObjectType)
+		require(resourceType.SourceObjectType)
+	}
+
+	return model
+}
+
 func (cfg *Config) Validate() error {
 	if cfg.User.ObjectType == "" {
 		return errors.Wrap(ErrInvalidConfig, "scim.user_object_type is required")
@@ -74,6 +223,10 @@ func (cfg *Config) Validate() error {
 		return errors.Wrap(ErrInvalidConfig, "identity relation is required")
 	}
 
+	if err := validateSchemaExtensions("scim.user", cfg.User.SchemaExtensions); err != nil {
+		return err
+	}
+
 	if cfg.Group != nil {
 		if cfg.Group.ObjectType == "" {
 			return errors.Wrap(ErrInvalidConfig, "scim.group_object_type is required")
@@ -86,11 +239,139 @@ func (cfg *Config) Validate() error {
 		if cfg.Group.GroupMemberRelation == "" {
 			return errors.Wrap(ErrInvalidConfig, "scim.group_member_relation is required")
 		}
+
+		if err := validateSchemaExtensions("scim.group", cfg.Group.SchemaExtensions); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if cfg.Role != nil {
+		if cfg.Role.ObjectType == "" {
+			return errors.Wrap(ErrInvalidConfig, "scim.role.object_type is required")
+		}
+
+		if cfg.Role.RoleRelation == "" {
+			return errors.Wrap(ErrInvalidConfig, "scim.role.role_relation is required")
+		}
+	}
+
+	return validateResourceTypes(cfg.ResourceTypes)
 }
 
 func (c *Config) HasGroups() bool {
 	return c.Group != nil
 }
+
+func (c *Config) HasRoles() bool {
+	return c.Role != nil
+}
+
+func validateResourceTypes(resourceTypes []*ResourceType) error {
+	names := map[string]bool{}
+	endpoints := slices.Clone(reservedEndpoints)
+
+	for _, rt := range resourceTypes {
+		prefix := "scim.resource_types[" + rt.Name + "]"
+
+		switch {
+		case !resourceTypeName.MatchString(rt.Name):
+			return errors.Wrapf(ErrInvalidConfig, "scim.resource_types: invalid name [%s]", rt.Name)
+		case names[strings.ToLower(rt.Name)]:
+			return errors.Wrapf(ErrInvalidConfig, "scim.resource_types: duplicate name [%s]", rt.Name)
+		case !strings.HasPrefix(rt.Endpoint, "/") || strings.Count(rt.Endpoint, "/") != 1:
+			return errors.Wrapf(ErrInvalidConfig, "%s.endpoint [%s] must be a single path segment like /Devices", prefix, rt.Endpoint)
+		case slices.Contains(endpoints, strings.ToLower(rt.Endpoint)):
+			return errors.Wrapf(ErrInvalidConfig, "%s.endpoint [%s] is already in use", prefix, rt.Endpoint)
+		case rt.Schema == nil || !strings.HasPrefix(rt.Schema.ID, "urn:"):
+			return errors.Wrapf(ErrInvalidConfig, "%s.schema.id must be a URN", prefix)
+		case rt.SourceObjectType == "":
+			return errors.Wrapf(ErrInvalidConfig, "%s.source_object_type is required", prefix)
+		case rt.ObjectType == "":
+			return errors.Wrapf(ErrInvalidConfig, "%s.object_type is required", prefix)
+		}
+
+		names[strings.ToLower(rt.Name)] = true
+		endpoints = append(endpoints, strings.ToLower(rt.Endpoint))
+
+		if err := validateAttributes(prefix+".schema", rt.Schema.Attributes, true); err != nil {
+			return err
+		}
+
+		if err := validateSchemaExtensions(prefix, rt.SchemaExtensions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateSchemaExtensions(prefix string, extensions []*SchemaExtension) error {
+	ids := map[string]bool{}
+
+	for _, extension := range extensions {
+		if !strings.HasPrefix(extension.ID, "urn:") {
+			return errors.Wrapf(ErrInvalidConfig, "%s.schema_extensions: id [%s] must be a URN", prefix, extension.ID)
+		}
+
+		if ids[strings.ToLower(extension.ID)] {
+			return errors.Wrapf(ErrInvalidConfig, "%s.schema_extensions: duplicate id [%s]", prefix, extension.ID)
+		}
+
+		ids[strings.ToLower(extension.ID)] = true
+
+		if err := validateAttributes(prefix+".schema_extensions["+extension.ID+"]", extension.Attributes, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateAttributes(prefix string, attributes []*Attribute, allowComplex bool) error {
+	names := map[string]bool{}
+
+	for _, attr := range attributes {
+		if !attributeName.MatchString(attr.Name) {
+			return errors.Wrapf(ErrInvalidConfig, "%s: invalid attribute name [%s]", prefix, attr.Name)
+		}
+
+		if names[strings.ToLower(attr.Name)] {
+			return errors.Wrapf(ErrInvalidConfig, "%s: duplicate attribute [%s]", prefix, attr.Name)
+		}
+
+		names[strings.ToLower(attr.Name)] = true
+
+		switch {
+		case !slices.Contains(attributeTypes, attr.Type):
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: invalid type [%s]", prefix, attr.Name, attr.Type)
+		case !slices.Contains(attributeMutabilities, attr.Mutability):
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: invalid mutability [%s]", prefix, attr.Name, attr.Mutability)
+		case !slices.Contains(attributeReturned, attr.Returned):
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: invalid returned [%s]", prefix, attr.Name, attr.Returned)
+		case !slices.Contains(attributeUniqueness, attr.Uniqueness):
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: invalid uniqueness [%s]", prefix, attr.Name, attr.Uniqueness)
+		}
+
+		if attr.Type != AttributeTypeComplex {
+			if len(attr.SubAttributes) > 0 {
+				return errors.Wrapf(ErrInvalidConfig, "%s.%s: only complex attributes have sub-attributes", prefix, attr.Name)
+			}
+
+			continue
+		}
+
+		if !allowComplex {
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: sub-attributes can't be complex", prefix, attr.Name)
+		}
+
+		if len(attr.SubAttributes) == 0 {
+			return errors.Wrapf(ErrInvalidConfig, "%s.%s: complex attributes require sub-attributes", prefix, attr.Name)
+		}
+
+		if err := validateAttributes(prefix+"."+attr.Name, attr.SubAttributes, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aserto-dev/ds-load/sdk/common/msg"
 	"github.com/aserto-dev/ds-load/sdk/transform"
 	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/config"
 	"github.com/aserto-dev/scim/common/model"
 	"github.com/elimity-com/scim"
 	"github.com/elimity-com/scim/optional"
@@ -105,6 +106,35 @@ func (c *Converter) SCIMUserToObject(user *model.User) (*dsc.Object, error) {
 	return object, nil
 }
 
+// SetExtensionProperties adds the attributes of custom schema extensions, which the user and group models
+// don't hold, to the properties of a source object.
+func (c *Converter) SetExtensionProperties(
+	object *dsc.Object,
+	attributes scim.ResourceAttributes,
+	extensions []*config.SchemaExtension,
+) error {
+	if len(extensions) == 0 {
+		return nil
+	}
+
+	properties := object.GetProperties().AsMap()
+
+	for _, extension := range extensions {
+		if value, ok := attributes[extension.ID]; ok {
+			properties[extension.ID] = value
+		}
+	}
+
+	props, err := structpb.NewStruct(properties)
+	if err != nil {
+		return err
+	}
+
+	object.Properties = props
+
+	return nil
+}
+
 func (c *Converter) SCIMGroupToObject(group *model.Group) (*dsc.Object, error) {
 	if c.cfg.Group == nil {
 		return nil, ErrGroupsNotEnabled
@@ -135,6 +165,25 @@ func (c *Converter) SCIMGroupToObject(group *model.Group) (*dsc.Object, error) {
 }
 
 func (c *Converter) TransformResource(resource map[string]any, id, objType string) (*msg.Transform, error) {
+	return c.transform(resource, id, objType, nil)
+}
+
+// TransformResourceType transforms a resource of a resource type declared in the configuration, using the template
+// section of the resource type. The template can read the resource type configuration from .resourceType.
+func (c *Converter) TransformResourceType(
+	resource map[string]any,
+	id string,
+	resourceType *config.ResourceType,
+) (*msg.Transform, error) {
+	vars := map[string]any{}
+	if err := Unmarshal(resourceType, &vars); err != nil {
+		return nil, err
+	}
+
+	return c.transform(resource, id, resourceType.Section(), vars)
+}
+
+func (c *Converter) transform(resource map[string]any, id, objType string, resourceType map[string]any) (*msg.Transform, error) {
 	vars, err := c.cfg.ToTemplateVars()
 	if err != nil {
 		return nil, err
@@ -143,10 +192,11 @@ func (c *Converter) TransformResource(resource map[string]any, id, objType strin
 	transformer := transform.NewGoTemplateTransform(c.cfg.Template())
 
 	transformInput := map[string]any{
-		"input":      resource,
-		"vars":       vars,
-		"objectType": objType,
-		"objectId":   id,
+		"input":        resource,
+		"vars":         vars,
+		"objectType":   objType,
+		"objectId":     id,
+		"resourceType": resourceType,
 	}
 
 	return transformer.TransformObject(transformInput)
@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"strings"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/elimity-com/scim"
+)
+
+const weakETagPrefix = "W/"
+
+// ETag formats a directory object etag as a weak HTTP entity tag, e.g. W/"1234".
+func ETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+
+	return weakETagPrefix + `"` + etag + `"`
+}
+
+// MatchETag reports whether any of the entity tags in an If-Match or If-None-Match header value matches
+// the given version, using the weak comparison function of RFC 7232 section 2.3.2.
+func MatchETag(header, version string) bool {
+	version = opaqueTag(version)
+	if version == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || opaqueTag(tag) == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObjectMeta returns the SCIM resource metadata of a directory object.
+func ObjectMeta(object *dsc.Object) scim.Meta {
+	createdAt := object.GetCreatedAt().AsTime()
+	updatedAt := object.GetUpdatedAt().AsTime()
+
+	return scim.Meta{
+		Created:      &createdAt,
+		LastModified: &updatedAt,
+		Version:      ETag(object.GetEtag()),
+	}
+}
+
+func opaqueTag(tag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), weakETagPrefix), `"`)
+}
@@ -0,0 +1,30 @@
+package convert_test
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version string
+		match   bool
+	}{
+		{name: "weak tag", header: `W/"123"`, version: `W/"123"`, match: true},
+		{name: "strong tag", header: `"123"`, version: `W/"123"`, match: true},
+		{name: "list", header: `"abc", W/"123"`, version: `W/"123"`, match: true},
+		{name: "wildcard", header: "*", version: `W/"123"`, match: true},
+		{name: "mismatch", header: `W/"124"`, version: `W/"123"`, match: false},
+		{name: "no version", header: "*", version: "", match: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.New(t).Equal(tc.match, convert.MatchETag(tc.header, tc.version))
+		})
+	}
+}
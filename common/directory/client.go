@@ -3,6 +3,8 @@ package directory
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
 	"slices"
 
 	"github.com/aserto-dev/ds-load/sdk/common/msg"
@@ -12,16 +14,26 @@ import (
 	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
 	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
 	"github.com/aserto-dev/go-directory/pkg/derr"
+	"github.com/aserto-dev/scim/common"
 	"github.com/aserto-dev/scim/common/convert"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
 	"github.com/hashicorp/go-multierror"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// objectsPageSize is the number of objects requested per directory page when listing objects.
+const objectsPageSize = 100
+
+var tracer = otel.Tracer("github.com/aserto-dev/scim/common/directory")
+
 type Client struct {
 	cfg    *convert.TransformConfig
 	client *ds.Client
@@ -40,30 +52,59 @@ func (s *Client) DS() *ds.Client {
 	return s.client
 }
 
+// ForEachObject calls fn for every object of the given type, following the directory page tokens until
+// all objects have been read.
+func (s *Client) ForEachObject(ctx context.Context, objectType string, fn func(*dsc.Object) error) error {
+	pageToken := ""
+
+	for {
+		resp, err := s.client.Reader.GetObjects(ctx, &dsr.GetObjectsRequest{
+			ObjectType: objectType,
+			Page: &dsc.PaginationRequest{
+				Size:  objectsPageSize,
+				Token: pageToken,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, object := range resp.GetResults() {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+
+		pageToken = resp.GetPage().GetNextToken()
+		if pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// SetUser reconciles the user objects, identities and relations produced by the transform with the ones
+// in the directory. Objects and relations that are already up to date are left untouched, and identities
+// and manager relations that the transform no longer produces are removed.
 func (s *Client) SetUser(ctx context.Context, userID string, data *msg.Transform, userAttributes scim.ResourceAttributes) (scim.Meta, error) {
-	logger := s.logger.With().Str("method", "SetUser").Str("id", userID).Logger()
+	ctx, span := tracer.Start(ctx, "directory.SetUser", trace.WithAttributes(attribute.String("scim.id", userID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "SetUser").Str("id", userID).Logger()
 	logger.Trace().Msg("set user")
 
-	idRelation, err := s.cfg.ParseIdentityRelation(userID, "")
+	identityRelations, err := s.getUserIdentityRelations(ctx, userID)
 	if err != nil {
 		return scim.Meta{}, err
 	}
 
-	relations, err := s.client.Reader.GetRelations(ctx, &dsr.GetRelationsRequest{
-		ObjectType:               idRelation.GetObjectType(),
-		ObjectId:                 idRelation.GetObjectId(),
-		Relation:                 idRelation.GetRelation(),
-		SubjectType:              idRelation.GetSubjectType(),
-		SubjectId:                idRelation.GetSubjectId(),
-		WithObjects:              false,
-		WithEmptySubjectRelation: true,
-	})
+	managerRelations, err := s.getUserManagerRelations(ctx, userID)
 	if err != nil {
-		st, ok := status.FromError(err)
+		return scim.Meta{}, err
+	}
 
-		if ok && st.Code() != codes.NotFound {
-			return scim.Meta{}, err
-		}
+	roleRelations, err := s.getUserRoleRelations(ctx, userID)
+	if err != nil {
+		return scim.Meta{}, err
 	}
 
 	result, addedIdentities, err := s.importObjects(ctx, data.GetObjects(), userAttributes)
@@ -73,10 +114,16 @@ func (s *Client) SetUser(ctx context.Context, userID string, data *msg.Transform
 
 	logger.Trace().Any("identities", addedIdentities).Msg("added identities")
 
+	existingRelations := slices.Concat(identityRelations, managerRelations, roleRelations)
+
 	for _, relation := range data.GetRelations() {
+		if slices.ContainsFunc(existingRelations, sameRelation(relation)) {
+			continue
+		}
+
 		logger.Trace().Any("relation", relation).Msg("setting relation")
 
-		_, err := s.client.Writer.SetRelation(ctx, &dsw.SetRelationRequest{
+		_, err := s.SetRelation(ctx, &dsw.SetRelationRequest{
 			Relation: relation,
 		})
 		if err != nil {
@@ -86,51 +133,170 @@ func (s *Client) SetUser(ctx context.Context, userID string, data *msg.Transform
 
 	mErr := &multierror.Error{}
 
-	for _, rel := range relations.GetResults() {
-		if !slices.Contains(addedIdentities, rel.GetObjectId()) {
-			logger.Trace().Str("identity", rel.GetObjectId()).Msg("deleting identity")
+	for _, rel := range managerRelations {
+		if slices.ContainsFunc(data.GetRelations(), sameRelation(rel)) {
+			continue
+		}
 
-			_, err := s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
-				ObjectType:    s.cfg.User.IdentityObjectType,
-				ObjectId:      rel.GetObjectId(),
-				WithRelations: true,
-			})
-			if err != nil {
-				mErr = multierror.Append(mErr, err)
-				logger.Err(err).Str("identity", rel.GetObjectId()).Msg("failed to delete identity")
-			}
+		logger.Trace().Str("manager", rel.GetSubjectId()).Msg("deleting manager relation")
+
+		if err := s.deleteRelation(ctx, rel); err != nil {
+			mErr = multierror.Append(mErr, err)
+			logger.Err(err).Str("manager", rel.GetSubjectId()).Msg("failed to delete manager relation")
+		}
+	}
+
+	for _, rel := range roleRelations {
+		if slices.ContainsFunc(data.GetRelations(), sameRelation(rel)) {
+			continue
+		}
+
+		logger.Trace().Str("role", rel.GetObjectId()).Msg("deleting role relation")
+
+		if err := s.deleteRelation(ctx, rel); err != nil {
+			mErr = multierror.Append(mErr, err)
+			logger.Err(err).Str("role", rel.GetObjectId()).Msg("failed to delete role relation")
+		}
+	}
+
+	for _, rel := range identityRelations {
+		identity := s.identityID(rel)
+		if slices.Contains(addedIdentities, identity) {
+			continue
+		}
+
+		logger.Trace().Str("identity", identity).Msg("deleting identity")
+
+		_, err := s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+			ObjectType:    s.cfg.User.IdentityObjectType,
+			ObjectId:      identity,
+			WithRelations: true,
+		})
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+			logger.Err(err).Str("identity", identity).Msg("failed to delete identity")
 		}
 	}
 
 	return result, mErr.ErrorOrNil()
 }
 
-func (s *Client) importObjects(ctx context.Context, objects []*dsc.Object, userAttributes scim.ResourceAttributes) (scim.Meta, []string, error) {
-	var err error
+func (s *Client) getUserIdentityRelations(ctx context.Context, userID string) ([]*dsc.Relation, error) {
+	idRelation, err := s.cfg.ParseIdentityRelation(userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType:               idRelation.GetObjectType(),
+		ObjectId:                 idRelation.GetObjectId(),
+		Relation:                 idRelation.GetRelation(),
+		SubjectType:              idRelation.GetSubjectType(),
+		SubjectId:                idRelation.GetSubjectId(),
+		WithEmptySubjectRelation: true,
+	})
+}
 
+func (s *Client) getUserManagerRelations(ctx context.Context, userID string) ([]*dsc.Relation, error) {
+	if s.cfg.User.ManagerRelation == "" {
+		return nil, nil
+	}
+
+	return s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType:               s.cfg.User.ObjectType,
+		ObjectId:                 userID,
+		Relation:                 s.cfg.User.ManagerRelation,
+		SubjectType:              s.cfg.User.ObjectType,
+		WithEmptySubjectRelation: true,
+	})
+}
+
+// getRelations returns all relations matching the request, following the directory page tokens.
+func (s *Client) getRelations(ctx context.Context, req *dsr.GetRelationsRequest) ([]*dsc.Relation, error) {
+	relations, _, err := s.getRelationsWithObjects(ctx, req)
+	return relations, err
+}
+
+// getRelationsWithObjects returns all relations matching the request and, if the request asks for them,
+// the objects of the relations keyed by "type:id".
+func (s *Client) getRelationsWithObjects(
+	ctx context.Context,
+	req *dsr.GetRelationsRequest,
+) ([]*dsc.Relation, map[string]*dsc.Object, error) {
+	relations := make([]*dsc.Relation, 0)
+	objects := make(map[string]*dsc.Object)
+	req.Page = &dsc.PaginationRequest{Size: objectsPageSize}
+
+	for {
+		resp, err := s.client.Reader.GetRelations(ctx, req)
+		if err != nil {
+			if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+				return relations, objects, nil
+			}
+
+			return nil, nil, err
+		}
+
+		relations = append(relations, resp.GetResults()...)
+		maps.Copy(objects, resp.GetObjects())
+
+		req.Page.Token = resp.GetPage().GetNextToken()
+		if req.Page.GetToken() == "" {
+			return relations, objects, nil
+		}
+	}
+}
+
+// identityID returns the id of the identity object of an identity relation, which can be either
+// the object or the subject of the relation depending on the configured identity relation.
+func (s *Client) identityID(rel *dsc.Relation) string {
+	if rel.GetObjectType() == s.cfg.User.IdentityObjectType {
+		return rel.GetObjectId()
+	}
+
+	return rel.GetSubjectId()
+}
+
+func sameRelation(relation *dsc.Relation) func(*dsc.Relation) bool {
+	return func(other *dsc.Relation) bool {
+		return relation.GetObjectType() == other.GetObjectType() &&
+			relation.GetObjectId() == other.GetObjectId() &&
+			relation.GetRelation() == other.GetRelation() &&
+			relation.GetSubjectType() == other.GetSubjectType() &&
+			relation.GetSubjectId() == other.GetSubjectId() &&
+			relation.GetSubjectRelation() == other.GetSubjectRelation()
+	}
+}
+
+func (s *Client) importObjects(ctx context.Context, objects []*dsc.Object, userAttributes scim.ResourceAttributes) (scim.Meta, []string, error) {
 	result := scim.Meta{}
 	addedIdentities := make([]string, 0)
 
 	for _, object := range objects {
+		current, err := s.getObject(ctx, object.GetType(), object.GetId())
+		if err != nil {
+			return result, addedIdentities, err
+		}
+
 		if object.GetType() == s.cfg.User.ObjectType {
-			var userProperties map[string]any
-			if object.GetProperties() == nil {
-				userProperties = make(map[string]any)
-			} else {
-				userProperties = object.GetProperties().AsMap()
+			if err := s.setUserProperties(object, current, userAttributes); err != nil {
+				return result, addedIdentities, err
 			}
+		}
 
-			for key, value := range s.cfg.User.PropertyMapping {
-				userProperties[key] = userAttributes[value]
+		if !objectChanged(current, object) {
+			if object.GetType() == s.cfg.User.IdentityObjectType {
+				addedIdentities = append(addedIdentities, current.GetId())
 			}
 
-			object.Properties, err = structpb.NewStruct(userProperties)
-			if err != nil {
-				return result, addedIdentities, err
+			if object.GetType() == s.cfg.User.ObjectType {
+				result = convert.ObjectMeta(current)
 			}
+
+			continue
 		}
 
-		resp, err := s.client.Writer.SetObject(ctx, &dsw.SetObjectRequest{
+		resp, err := s.SetObject(ctx, &dsw.SetObjectRequest{
 			Object: object,
 		})
 		if err != nil {
@@ -151,19 +317,85 @@ func (s *Client) importObjects(ctx context.Context, objects []*dsc.Object, userA
 				return result, addedIdentities, err
 			}
 
-			createdAt := resp.GetResult().GetCreatedAt().AsTime()
-			updatedAt := resp.GetResult().GetUpdatedAt().AsTime()
-			result.Created = &createdAt
-			result.LastModified = &updatedAt
-			result.Version = resp.GetResult().GetEtag()
+			result = convert.ObjectMeta(resp.GetResult())
 		}
 	}
 
 	return result, addedIdentities, nil
 }
 
+// setUserProperties sets the properties of a user object produced by the transform. Properties of the
+// existing user object are kept, so that properties written by other applications are preserved.
+func (s *Client) setUserProperties(object, current *dsc.Object, userAttributes scim.ResourceAttributes) error {
+	userProperties := current.GetProperties().AsMap()
+	maps.Copy(userProperties, object.GetProperties().AsMap())
+
+	for key, path := range s.cfg.User.PropertyMapping {
+		value, err := common.AttributeValue(userAttributes, path)
+		if err != nil {
+			return fmt.Errorf("invalid property mapping [%s]: %w", path, err)
+		}
+
+		userProperties[key] = value
+	}
+
+	props, err := structpb.NewStruct(userProperties)
+	if err != nil {
+		return err
+	}
+
+	object.Properties = props
+
+	return nil
+}
+
+// mergeProperties adds the properties of the existing object that the transform does not set to object.
+func mergeProperties(object, current *dsc.Object) error {
+	if current == nil {
+		return nil
+	}
+
+	properties := current.GetProperties().AsMap()
+	maps.Copy(properties, object.GetProperties().AsMap())
+
+	props, err := structpb.NewStruct(properties)
+	if err != nil {
+		return err
+	}
+
+	object.Properties = props
+
+	return nil
+}
+
+// getObject returns the object with the given type and id, or nil if it does not exist.
+func (s *Client) getObject(ctx context.Context, objectType, objectID string) (*dsc.Object, error) {
+	resp, err := s.client.Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType: objectType,
+		ObjectId:   objectID,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return resp.GetResult(), nil
+}
+
+func objectChanged(current, object *dsc.Object) bool {
+	return current == nil ||
+		current.GetDisplayName() != object.GetDisplayName() ||
+		!proto.Equal(current.GetProperties(), object.GetProperties())
+}
+
 func (s *Client) DeleteUser(ctx context.Context, userID string) error {
-	logger := s.logger.With().Str("method", "DeleteUser").Str("id", userID).Logger()
+	ctx, span := tracer.Start(ctx, "directory.DeleteUser", trace.WithAttributes(attribute.String("scim.id", userID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "DeleteUser").Str("id", userID).Logger()
 	logger.Trace().Msg("delete user")
 
 	identityRelation, err := s.cfg.ParseIdentityRelation(userID, "")
@@ -200,7 +432,7 @@ func (s *Client) DeleteUser(ctx context.Context, userID string) error {
 
 		logger.Trace().Str("id", v.GetObjectId()).Msg("deleting identity")
 
-		_, err = s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+		_, err = s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 			ObjectId:      objectID,
 			ObjectType:    s.cfg.User.IdentityObjectType,
 			WithRelations: true,
@@ -212,7 +444,7 @@ func (s *Client) DeleteUser(ctx context.Context, userID string) error {
 
 	logger.Trace().Msg("deleting user")
 
-	_, err = s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err = s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectType:    s.cfg.User.ObjectType,
 		ObjectId:      userID,
 		WithRelations: true,
@@ -222,7 +454,10 @@ func (s *Client) DeleteUser(ctx context.Context, userID string) error {
 }
 
 func (s *Client) SetGroup(ctx context.Context, groupID string, data *msg.Transform) (scim.Meta, error) {
-	logger := s.logger.With().Str("method", "SetGroup").Str("id", groupID).Logger()
+	ctx, span := tracer.Start(ctx, "directory.SetGroup", trace.WithAttributes(attribute.String("scim.id", groupID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "SetGroup").Str("id", groupID).Logger()
 	logger.Trace().Msg("set group")
 
 	if s.cfg.Group == nil {
@@ -235,12 +470,12 @@ func (s *Client) SetGroup(ctx context.Context, groupID string, data *msg.Transfo
 		return scim.Meta{}, err
 	}
 
-	result, err := s.processGroupObjects(ctx, data.GetObjects(), logger)
+	result, err := s.setObjects(ctx, data.GetObjects(), s.cfg.Group.ObjectType, logger)
 	if err != nil {
 		return result, err
 	}
 
-	addedMembers, err := s.processGroupRelations(ctx, data.GetRelations(), logger)
+	addedMembers, err := s.processGroupRelations(ctx, groupID, data.GetRelations(), existingRelations, logger)
 	if err != nil {
 		return result, err
 	}
@@ -252,31 +487,43 @@ func (s *Client) SetGroup(ctx context.Context, groupID string, data *msg.Transfo
 	return result, nil
 }
 
-func (s *Client) getGroupRelations(ctx context.Context, groupID string) (*dsr.GetRelationsResponse, error) {
-	relations, err := s.client.Reader.GetRelations(ctx, &dsr.GetRelationsRequest{
+// getGroupRelations returns all the member relations of a group.
+func (s *Client) getGroupRelations(ctx context.Context, groupID string) ([]*dsc.Relation, error) {
+	return s.getRelations(ctx, &dsr.GetRelationsRequest{
 		ObjectType:               s.cfg.Group.ObjectType,
 		ObjectId:                 groupID,
 		Relation:                 s.cfg.Group.GroupMemberRelation,
 		WithObjects:              false,
 		WithEmptySubjectRelation: true,
 	})
-	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() != codes.NotFound {
-			return nil, err
-		}
-	}
-
-	return relations, nil
 }
 
-func (s *Client) processGroupObjects(ctx context.Context, objects []*dsc.Object, logger zerolog.Logger) (scim.Meta, error) {
+// setObjects imports the objects produced by the transform of a group or another resource, keeping the properties
+// of existing objects that the transform doesn't set. The metadata of the object of the given type is returned.
+func (s *Client) setObjects(ctx context.Context, objects []*dsc.Object, objectType string, logger zerolog.Logger) (scim.Meta, error) {
 	var result scim.Meta
 
 	for _, object := range objects {
+		current, err := s.getObject(ctx, object.GetType(), object.GetId())
+		if err != nil {
+			return result, err
+		}
+
+		if err := mergeProperties(object, current); err != nil {
+			return result, err
+		}
+
+		if !objectChanged(current, object) {
+			if object.GetType() == objectType {
+				result = convert.ObjectMeta(current)
+			}
+
+			continue
+		}
+
 		logger.Trace().Any("object", object).Msg("setting object")
 
-		resp, err := s.client.Writer.SetObject(ctx, &dsw.SetObjectRequest{
+		resp, err := s.SetObject(ctx, &dsw.SetObjectRequest{
 			Object: object,
 		})
 		if err != nil {
@@ -287,19 +534,26 @@ func (s *Client) processGroupObjects(ctx context.Context, objects []*dsc.Object,
 			return result, err
 		}
 
-		if object.GetType() == s.cfg.Group.ObjectType {
+		if object.GetType() == objectType {
 			if err := s.setRelations(ctx, resp.GetResult().GetId(), resp.GetResult().GetType()); err != nil {
 				return result, err
 			}
 
-			result = s.updateMetaFromResponse(resp.GetResult())
+			result = convert.ObjectMeta(resp.GetResult())
 		}
 	}
 
 	return result, nil
 }
 
-func (s *Client) processGroupRelations(ctx context.Context, relations []*dsc.Relation, logger zerolog.Logger) ([]string, error) {
+// processGroupRelations sets the relations produced by the transform of a group and returns the added members.
+// Member relations of the group that already exist are not written again.
+func (s *Client) processGroupRelations(
+	ctx context.Context,
+	groupID string,
+	relations, existingRelations []*dsc.Relation,
+	logger zerolog.Logger,
+) ([]string, error) {
 	addedMembers := make([]string, 0)
 
 	for _, relation := range relations {
@@ -307,11 +561,22 @@ func (s *Client) processGroupRelations(ctx context.Context, relations []*dsc.Rel
 			addedMembers = append(addedMembers, relation.GetSubjectId())
 		}
 
+		if slices.ContainsFunc(existingRelations, sameRelation(relation)) {
+			continue
+		}
+
 		logger.Trace().Any("relation", relation).Msg("setting relation")
 
-		if _, err := s.client.Writer.SetRelation(ctx, &dsw.SetRelationRequest{
-			Relation: relation,
-		}); err != nil {
+		req := &dsw.SetRelationRequest{Relation: relation}
+
+		var err error
+		if s.isGroupMemberRelation(groupID, relation) {
+			_, err = s.setRelation(ctx, req, false)
+		} else {
+			_, err = s.SetRelation(ctx, req)
+		}
+
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -319,21 +584,26 @@ func (s *Client) processGroupRelations(ctx context.Context, relations []*dsc.Rel
 	return addedMembers, nil
 }
 
+// isGroupMemberRelation reports whether a relation is a member relation of the group without subject relation,
+// which are all read before the group is set.
+func (s *Client) isGroupMemberRelation(groupID string, relation *dsc.Relation) bool {
+	return relation.GetObjectType() == s.cfg.Group.ObjectType &&
+		relation.GetObjectId() == groupID &&
+		relation.GetRelation() == s.cfg.Group.GroupMemberRelation &&
+		relation.GetSubjectRelation() == ""
+}
+
 func (s *Client) removeStaleRelations(ctx context.Context,
-	relations *dsr.GetRelationsResponse,
+	relations []*dsc.Relation,
 	addedMembers []string,
 	groupID string,
 	logger zerolog.Logger,
 ) error {
-	if relations == nil {
-		return nil
-	}
-
-	for _, rel := range relations.GetResults() {
+	for _, rel := range relations {
 		if !slices.Contains(addedMembers, rel.GetSubjectId()) {
 			logger.Trace().Str("id", rel.GetSubjectId()).Msg("deleting relation")
 
-			if err := s.deleteGroupRelation(ctx, groupID, rel); err != nil {
+			if err := s.deleteRelation(ctx, rel); err != nil {
 				return err
 			}
 		}
@@ -343,7 +613,7 @@ func (s *Client) removeStaleRelations(ctx context.Context,
 }
 
 func (s *Client) deleteGroupRelation(ctx context.Context, groupID string, rel *dsc.Relation) error {
-	_, err := s.client.Writer.DeleteRelation(ctx, &dsw.DeleteRelationRequest{
+	_, err := s.DeleteRelation(ctx, &dsw.DeleteRelationRequest{
 		ObjectType:  s.cfg.Group.ObjectType,
 		ObjectId:    groupID,
 		Relation:    s.cfg.Group.GroupMemberRelation,
@@ -354,22 +624,42 @@ func (s *Client) deleteGroupRelation(ctx context.Context, groupID string, rel *d
 	return err
 }
 
-func (s *Client) updateMetaFromResponse(result *dsc.Object) scim.Meta {
-	createdAt := result.GetCreatedAt().AsTime()
-	updatedAt := result.GetUpdatedAt().AsTime()
+// AddGroupMember adds a user to a group by writing a single group member relation.
+func (s *Client) AddGroupMember(ctx context.Context, groupID, userID string) error {
+	_, err := s.SetRelation(ctx, &dsw.SetRelationRequest{
+		Relation: &dsc.Relation{
+			ObjectType:  s.cfg.Group.ObjectType,
+			ObjectId:    groupID,
+			Relation:    s.cfg.Group.GroupMemberRelation,
+			SubjectType: s.cfg.User.ObjectType,
+			SubjectId:   userID,
+		},
+	})
+
+	return err
+}
 
-	return scim.Meta{
-		Created:      &createdAt,
-		LastModified: &updatedAt,
-		Version:      result.GetEtag(),
+// RemoveGroupMember removes a user from a group by deleting a single group member relation.
+func (s *Client) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	err := s.deleteGroupRelation(ctx, groupID, &dsc.Relation{
+		SubjectType: s.cfg.User.ObjectType,
+		SubjectId:   userID,
+	})
+	if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+		return nil
 	}
+
+	return err
 }
 
 func (s *Client) DeleteGroup(ctx context.Context, groupID string) error {
-	logger := s.logger.With().Str("method", "DeleteGroup").Str("id", groupID).Logger()
+	ctx, span := tracer.Start(ctx, "directory.DeleteGroup", trace.WithAttributes(attribute.String("scim.id", groupID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "DeleteGroup").Str("id", groupID).Logger()
 	logger.Trace().Msg("delete group")
 
-	_, err := s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err := s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectType:    s.cfg.Group.SourceObjectType,
 		ObjectId:      groupID,
 		WithRelations: true,
@@ -378,7 +668,7 @@ func (s *Client) DeleteGroup(ctx context.Context, groupID string) error {
 		return err
 	}
 
-	_, err = s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err = s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectType:    s.cfg.Group.ObjectType,
 		ObjectId:      groupID,
 		WithRelations: true,
@@ -390,7 +680,7 @@ func (s *Client) DeleteGroup(ctx context.Context, groupID string) error {
 func (s *Client) setRelations(ctx context.Context, subjID, subjType string) error {
 	for _, userMap := range s.cfg.Relations {
 		if userMap.SubjectID == subjID && userMap.SubjectType == subjType {
-			_, err := s.client.Writer.SetRelation(ctx, &dsw.SetRelationRequest{
+			_, err := s.SetRelation(ctx, &dsw.SetRelationRequest{
 				Relation: &dsc.Relation{
 					SubjectType:     userMap.SubjectType,
 					SubjectId:       userMap.SubjectID,
@@ -0,0 +1,53 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(dir *directorytest.Directory) *Client {
+	logger := zerolog.Nop()
+	cfg := &convert.TransformConfig{Config: &config.Config{
+		User:  &config.User{ObjectType: "user"},
+		Group: &config.Group{ObjectType: "group", GroupMemberRelation: "member"},
+		Role:  &config.Role{ObjectType: "role", RoleRelation: "assignee"},
+	}}
+
+	return NewDirectoryClient(cfg, &logger, dir.Client())
+}
+
+func TestRemoveStaleRelationsPastFirstPage(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	client := newTestClient(dir)
+	ctx := context.Background()
+
+	members := make([]string, 0, 2*objectsPageSize+10)
+	for i := range cap(members) {
+		members = append(members, fmt.Sprintf("user-%03d", i))
+		dir.AddRelation(&dsc.Relation{
+			ObjectType: "group", ObjectId: "council", Relation: "member", SubjectType: "user", SubjectId: members[i],
+		})
+	}
+
+	relations, err := client.getGroupRelations(ctx, "council")
+	assert.NoError(err)
+	assert.Len(relations, len(members))
+
+	kept := members[len(members)-1]
+	assert.NoError(client.removeStaleRelations(ctx, relations, []string{kept}, "council", zerolog.Nop()))
+
+	remaining, err := client.getGroupRelations(ctx, "council")
+	assert.NoError(err)
+	assert.Len(remaining, 1)
+	assert.Equal(kept, remaining[0].GetSubjectId())
+}
@@ -0,0 +1,226 @@
+// Package directorytest provides an in-memory directory for testing code that reads and writes directory objects
+// and relations.
+package directorytest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Directory is an in-memory directory that pages relations, checks object etags and keeps the creation time of
+// updated objects like the directory service.
+type Directory struct {
+	dsr.ReaderClient
+	dsw.WriterClient
+
+	Objects   map[string]*dsc.Object
+	Relations map[string]*dsc.Relation
+	version   int
+
+	// RelationReads counts the relations read one at a time, and writes of the relation keyed FailRelation fail.
+	RelationReads int
+	FailRelation  string
+}
+
+// NewDirectory returns an empty directory.
+func NewDirectory() *Directory {
+	return &Directory{
+		Objects:   make(map[string]*dsc.Object),
+		Relations: make(map[string]*dsc.Relation),
+	}
+}
+
+// Client returns a directory client that reads from and writes to the directory.
+func (d *Directory) Client() *ds.Client {
+	return &ds.Client{Reader: d, Writer: d}
+}
+
+// AddObject stores an object without going through the writer.
+func (d *Directory) AddObject(object *dsc.Object) {
+	d.Objects[object.GetType()+":"+object.GetId()] = object
+}
+
+// AddRelation stores a relation without going through the writer.
+func (d *Directory) AddRelation(relation *dsc.Relation) {
+	d.Relations[RelationKey(relation)] = relation
+}
+
+// RelationKey returns the key of a relation in Relations.
+func RelationKey(r *dsc.Relation) string {
+	return fmt.Sprintf("%s:%s#%s@%s:%s#%s", r.GetObjectType(), r.GetObjectId(), r.GetRelation(),
+		r.GetSubjectType(), r.GetSubjectId(), r.GetSubjectRelation())
+}
+
+func (d *Directory) GetObject(_ context.Context, in *dsr.GetObjectRequest, _ ...grpc.CallOption) (*dsr.GetObjectResponse, error) {
+	object, ok := d.Objects[in.GetObjectType()+":"+in.GetObjectId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "object not found")
+	}
+
+	return &dsr.GetObjectResponse{Result: proto.Clone(object).(*dsc.Object)}, nil
+}
+
+func (d *Directory) GetObjects(
+	_ context.Context,
+	in *dsr.GetObjectsRequest,
+	_ ...grpc.CallOption,
+) (*dsr.GetObjectsResponse, error) {
+	keys := make([]string, 0, len(d.Objects))
+
+	for key, object := range d.Objects {
+		if object.GetType() == in.GetObjectType() {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	start, _ := strconv.Atoi(in.GetPage().GetToken())
+	end := min(start+int(in.GetPage().GetSize()), len(keys))
+
+	resp := &dsr.GetObjectsResponse{Page: &dsc.PaginationResponse{}}
+	for _, key := range keys[start:end] {
+		resp.Results = append(resp.Results, proto.Clone(d.Objects[key]).(*dsc.Object))
+	}
+
+	if end < len(keys) {
+		resp.Page.NextToken = strconv.Itoa(end)
+	}
+
+	return resp, nil
+}
+
+func (d *Directory) GetRelation(
+	_ context.Context,
+	in *dsr.GetRelationRequest,
+	_ ...grpc.CallOption,
+) (*dsr.GetRelationResponse, error) {
+	d.RelationReads++
+
+	relation, ok := d.Relations[RelationKey(&dsc.Relation{
+		ObjectType: in.GetObjectType(), ObjectId: in.GetObjectId(), Relation: in.GetRelation(),
+		SubjectType: in.GetSubjectType(), SubjectId: in.GetSubjectId(), SubjectRelation: in.GetSubjectRelation(),
+	})]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "relation not found")
+	}
+
+	return &dsr.GetRelationResponse{Result: relation}, nil
+}
+
+func (d *Directory) GetRelations(
+	_ context.Context,
+	in *dsr.GetRelationsRequest,
+	_ ...grpc.CallOption,
+) (*dsr.GetRelationsResponse, error) {
+	matches := func(filter, value string) bool { return filter == "" || filter == value }
+
+	keys := make([]string, 0, len(d.Relations))
+
+	for key, r := range d.Relations {
+		if matches(in.GetObjectType(), r.GetObjectType()) && matches(in.GetObjectId(), r.GetObjectId()) &&
+			matches(in.GetRelation(), r.GetRelation()) && matches(in.GetSubjectType(), r.GetSubjectType()) &&
+			matches(in.GetSubjectId(), r.GetSubjectId()) && (!in.GetWithEmptySubjectRelation() || r.GetSubjectRelation() == "") {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	start, _ := strconv.Atoi(in.GetPage().GetToken())
+	end := min(start+int(in.GetPage().GetSize()), len(keys))
+
+	resp := &dsr.GetRelationsResponse{Page: &dsc.PaginationResponse{}}
+	for _, key := range keys[start:end] {
+		resp.Results = append(resp.Results, d.Relations[key])
+	}
+
+	if end < len(keys) {
+		resp.Page.NextToken = strconv.Itoa(end)
+	}
+
+	return resp, nil
+}
+
+func (d *Directory) SetObject(_ context.Context, in *dsw.SetObjectRequest, _ ...grpc.CallOption) (*dsw.SetObjectResponse, error) {
+	key := in.GetObject().GetType() + ":" + in.GetObject().GetId()
+
+	if current, ok := d.Objects[key]; ok && in.GetObject().GetEtag() != "" && in.GetObject().GetEtag() != current.GetEtag() {
+		return nil, status.Error(codes.FailedPrecondition, "etag mismatch")
+	}
+
+	d.version++
+
+	object := proto.Clone(in.GetObject()).(*dsc.Object)
+	object.Etag = strconv.Itoa(d.version)
+	object.UpdatedAt = timestamppb.New(time.Unix(int64(d.version), 0))
+	object.CreatedAt = object.GetUpdatedAt()
+
+	if current, ok := d.Objects[key]; ok {
+		object.CreatedAt = current.GetCreatedAt()
+	}
+
+	d.Objects[key] = object
+
+	return &dsw.SetObjectResponse{Result: proto.Clone(object).(*dsc.Object)}, nil
+}
+
+func (d *Directory) DeleteObject(
+	_ context.Context,
+	in *dsw.DeleteObjectRequest,
+	_ ...grpc.CallOption,
+) (*dsw.DeleteObjectResponse, error) {
+	delete(d.Objects, in.GetObjectType()+":"+in.GetObjectId())
+
+	if in.GetWithRelations() {
+		for key, r := range d.Relations {
+			if r.GetObjectType() == in.GetObjectType() && r.GetObjectId() == in.GetObjectId() ||
+				r.GetSubjectType() == in.GetObjectType() && r.GetSubjectId() == in.GetObjectId() {
+				delete(d.Relations, key)
+			}
+		}
+	}
+
+	return &dsw.DeleteObjectResponse{}, nil
+}
+
+func (d *Directory) SetRelation(
+	_ context.Context,
+	in *dsw.SetRelationRequest,
+	_ ...grpc.CallOption,
+) (*dsw.SetRelationResponse, error) {
+	relation := proto.Clone(in.GetRelation()).(*dsc.Relation)
+	if RelationKey(relation) == d.FailRelation {
+		return nil, status.Error(codes.Unavailable, "directory unavailable")
+	}
+
+	d.Relations[RelationKey(relation)] = relation
+
+	return &dsw.SetRelationResponse{Result: relation}, nil
+}
+
+func (d *Directory) DeleteRelation(
+	_ context.Context,
+	in *dsw.DeleteRelationRequest,
+	_ ...grpc.CallOption,
+) (*dsw.DeleteRelationResponse, error) {
+	delete(d.Relations, RelationKey(&dsc.Relation{
+		ObjectType: in.GetObjectType(), ObjectId: in.GetObjectId(), Relation: in.GetRelation(),
+		SubjectType: in.GetSubjectType(), SubjectId: in.GetSubjectId(), SubjectRelation: in.GetSubjectRelation(),
+	}))
+
+	return &dsw.DeleteRelationResponse{}, nil
+}
@@ -0,0 +1,342 @@
+package directory
+
+import (
+	"context"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/audit"
+	"github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// journal records a compensating action for every directory write made while provisioning a SCIM
+// resource, so that a failed request can restore the directory to the state it was in before the request.
+type journal struct {
+	undo []func(ctx context.Context) error
+}
+
+type journalKey struct{}
+
+func journalFromContext(ctx context.Context) *journal {
+	j, _ := ctx.Value(journalKey{}).(*journal)
+	return j
+}
+
+// Atomic runs fn with a context that journals the writes made through the client. If fn fails, the
+// journaled writes are undone in reverse order. Nested calls join the journal of the outermost call.
+func (s *Client) Atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if journalFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	j := &journal{}
+
+	err := fn(context.WithValue(ctx, journalKey{}, j))
+	if err == nil {
+		return nil
+	}
+
+	s.logger.Warn().Err(err).Int("writes", len(j.undo)).Msg("rolling back directory writes")
+
+	if rbErr := j.rollback(context.WithoutCancel(ctx)); rbErr != nil {
+		s.logger.Err(rbErr).Msg("failed to roll back directory writes")
+	} else {
+		audit.RecordRollback(ctx)
+	}
+
+	return err
+}
+
+func (j *journal) record(undo func(ctx context.Context) error) {
+	j.undo = append(j.undo, undo)
+}
+
+func (j *journal) rollback(ctx context.Context) error {
+	mErr := &multierror.Error{}
+
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		if err := j.undo[i](ctx); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// SetObject writes an object to the directory. Within Atomic, the previous state of the object is journaled.
+func (s *Client) SetObject(ctx context.Context, req *dsw.SetObjectRequest) (*dsw.SetObjectResponse, error) {
+	j := journalFromContext(ctx)
+	if j == nil {
+		return s.writeObject(ctx, req)
+	}
+
+	current, err := s.getObject(ctx, req.GetObject().GetType(), req.GetObject().GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.writeObject(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		j.record(s.undoSetObject(resp.GetResult()))
+	} else {
+		j.record(s.restoreObject(current))
+	}
+
+	return resp, nil
+}
+
+// DeleteObject deletes an object from the directory. Within Atomic, the object and, when the request
+// deletes them too, its relations are journaled.
+func (s *Client) DeleteObject(ctx context.Context, req *dsw.DeleteObjectRequest) (*dsw.DeleteObjectResponse, error) {
+	j := journalFromContext(ctx)
+	if j == nil {
+		return s.removeObject(ctx, req)
+	}
+
+	current, err := s.getObject(ctx, req.GetObjectType(), req.GetObjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	var relations []*dsc.Relation
+
+	if current != nil && req.GetWithRelations() {
+		if relations, err = s.getObjectRelations(ctx, req.GetObjectType(), req.GetObjectId()); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.removeObject(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, relation := range relations {
+		audit.RecordRelation(ctx, audit.ActionDeleteRelation, relation)
+	}
+
+	if current != nil {
+		restore := s.restoreObject(current)
+
+		j.record(func(ctx context.Context) error {
+			if err := restore(ctx); err != nil {
+				return err
+			}
+
+			for _, relation := range relations {
+				if _, err := s.client.Writer.SetRelation(ctx, &dsw.SetRelationRequest{Relation: relation}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return resp, nil
+}
+
+// SetRelation writes a relation to the directory. Within Atomic, relations that did not exist are journaled.
+func (s *Client) SetRelation(ctx context.Context, req *dsw.SetRelationRequest) (*dsw.SetRelationResponse, error) {
+	if journalFromContext(ctx) == nil {
+		return s.writeRelation(ctx, req)
+	}
+
+	exists, err := s.relationExists(ctx, req.GetRelation())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setRelation(ctx, req, exists)
+}
+
+// setRelation writes a relation whose existence is known, such as from the relations read to reconcile a
+// resource, which saves reading it again to journal the write.
+func (s *Client) setRelation(ctx context.Context, req *dsw.SetRelationRequest, exists bool) (*dsw.SetRelationResponse, error) {
+	resp, err := s.writeRelation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if j := journalFromContext(ctx); j != nil && !exists {
+		relation := req.GetRelation()
+
+		j.record(func(ctx context.Context) error {
+			_, err := s.client.Writer.DeleteRelation(ctx, &dsw.DeleteRelationRequest{
+				ObjectType:      relation.GetObjectType(),
+				ObjectId:        relation.GetObjectId(),
+				Relation:        relation.GetRelation(),
+				SubjectType:     relation.GetSubjectType(),
+				SubjectId:       relation.GetSubjectId(),
+				SubjectRelation: relation.GetSubjectRelation(),
+			})
+
+			return err
+		})
+	}
+
+	return resp, nil
+}
+
+// DeleteRelation deletes a relation from the directory. Within Atomic, the deleted relation is journaled.
+func (s *Client) DeleteRelation(ctx context.Context, req *dsw.DeleteRelationRequest) (*dsw.DeleteRelationResponse, error) {
+	if journalFromContext(ctx) == nil {
+		return s.removeRelation(ctx, req)
+	}
+
+	exists, err := s.relationExists(ctx, requestRelation(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.unsetRelation(ctx, req, exists)
+}
+
+// unsetRelation deletes a relation whose existence is known, like setRelation.
+func (s *Client) unsetRelation(ctx context.Context, req *dsw.DeleteRelationRequest, exists bool) (*dsw.DeleteRelationResponse, error) {
+	resp, err := s.removeRelation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if j := journalFromContext(ctx); j != nil && exists {
+		relation := requestRelation(req)
+
+		j.record(func(ctx context.Context) error {
+			_, err := s.client.Writer.SetRelation(ctx, &dsw.SetRelationRequest{Relation: relation})
+			return err
+		})
+	}
+
+	return resp, nil
+}
+
+func requestRelation(req *dsw.DeleteRelationRequest) *dsc.Relation {
+	return &dsc.Relation{
+		ObjectType:      req.GetObjectType(),
+		ObjectId:        req.GetObjectId(),
+		Relation:        req.GetRelation(),
+		SubjectType:     req.GetSubjectType(),
+		SubjectId:       req.GetSubjectId(),
+		SubjectRelation: req.GetSubjectRelation(),
+	}
+}
+
+// writeObject writes an object and records it in the audit trail of the operation.
+func (s *Client) writeObject(ctx context.Context, req *dsw.SetObjectRequest) (*dsw.SetObjectResponse, error) {
+	resp, err := s.client.Writer.SetObject(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordObject(ctx, audit.ActionSetObject, resp.GetResult())
+
+	return resp, nil
+}
+
+// removeObject deletes an object and records it in the audit trail of the operation.
+func (s *Client) removeObject(ctx context.Context, req *dsw.DeleteObjectRequest) (*dsw.DeleteObjectResponse, error) {
+	resp, err := s.client.Writer.DeleteObject(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordObject(ctx, audit.ActionDeleteObject, &dsc.Object{Type: req.GetObjectType(), Id: req.GetObjectId()})
+
+	return resp, nil
+}
+
+// writeRelation writes a relation and records it in the audit trail of the operation.
+func (s *Client) writeRelation(ctx context.Context, req *dsw.SetRelationRequest) (*dsw.SetRelationResponse, error) {
+	resp, err := s.client.Writer.SetRelation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordRelation(ctx, audit.ActionSetRelation, req.GetRelation())
+
+	return resp, nil
+}
+
+// removeRelation deletes a relation and records it in the audit trail of the operation.
+func (s *Client) removeRelation(ctx context.Context, req *dsw.DeleteRelationRequest) (*dsw.DeleteRelationResponse, error) {
+	resp, err := s.client.Writer.DeleteRelation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordRelation(ctx, audit.ActionDeleteRelation, requestRelation(req))
+
+	return resp, nil
+}
+
+func (s *Client) undoSetObject(object *dsc.Object) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := s.client.Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+			ObjectType:    object.GetType(),
+			ObjectId:      object.GetId(),
+			WithRelations: true,
+		})
+
+		return err
+	}
+}
+
+func (s *Client) restoreObject(object *dsc.Object) func(ctx context.Context) error {
+	restored, _ := proto.Clone(object).(*dsc.Object)
+	restored.Etag = ""
+
+	return func(ctx context.Context) error {
+		_, err := s.client.Writer.SetObject(ctx, &dsw.SetObjectRequest{Object: restored})
+		return err
+	}
+}
+
+// getObjectRelations returns the relations in which the object is either the object or the subject.
+func (s *Client) getObjectRelations(ctx context.Context, objectType, objectID string) ([]*dsc.Relation, error) {
+	objectRelations, err := s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType: objectType,
+		ObjectId:   objectID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subjectRelations, err := s.getRelations(ctx, &dsr.GetRelationsRequest{
+		SubjectType: objectType,
+		SubjectId:   objectID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(objectRelations, subjectRelations...), nil
+}
+
+func (s *Client) relationExists(ctx context.Context, relation *dsc.Relation) (bool, error) {
+	_, err := s.client.Reader.GetRelation(ctx, &dsr.GetRelationRequest{
+		ObjectType:      relation.GetObjectType(),
+		ObjectId:        relation.GetObjectId(),
+		Relation:        relation.GetRelation(),
+		SubjectType:     relation.GetSubjectType(),
+		SubjectId:       relation.GetSubjectId(),
+		SubjectRelation: relation.GetSubjectRelation(),
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
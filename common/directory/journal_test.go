@@ -0,0 +1,164 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/audit"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func memberRelation(groupID, userID string) *dsc.Relation {
+	return &dsc.Relation{ObjectType: "group", ObjectId: groupID, Relation: "member", SubjectType: "user", SubjectId: userID}
+}
+
+func deleteRelationRequest(r *dsc.Relation) *dsw.DeleteRelationRequest {
+	return &dsw.DeleteRelationRequest{
+		ObjectType:  r.GetObjectType(),
+		ObjectId:    r.GetObjectId(),
+		Relation:    r.GetRelation(),
+		SubjectType: r.GetSubjectType(),
+		SubjectId:   r.GetSubjectId(),
+	}
+}
+
+func userObject(t *testing.T, id, title string) *dsc.Object {
+	t.Helper()
+
+	properties, err := structpb.NewStruct(map[string]any{"title": title})
+	require.NoError(t, err)
+
+	return &dsc.Object{Type: "user", Id: id, Properties: properties}
+}
+
+func TestAtomicRollsBackFailedWrites(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	client := newTestClient(dir)
+
+	// The rick object gets a new etag when it is overwritten, which its restore must not send.
+	ctx := context.Background()
+	_, err := dir.SetObject(ctx, &dsw.SetObjectRequest{Object: userObject(t, "rick", "scientist")})
+	assert.NoError(err)
+	_, err = dir.SetObject(ctx, &dsw.SetObjectRequest{Object: &dsc.Object{Type: "group", Id: "admins"}})
+	assert.NoError(err)
+
+	dir.AddRelation(memberRelation("council", "rick"))
+	dir.AddRelation(memberRelation("admins", "rick"))
+	dir.FailRelation = directorytest.RelationKey(memberRelation("council", "morty"))
+
+	ctx, rec := audit.WithRecorder(ctx)
+
+	err = client.Atomic(ctx, func(ctx context.Context) error {
+		if _, err := client.SetObject(ctx, &dsw.SetObjectRequest{Object: userObject(t, "rick", "engineer")}); err != nil {
+			return err
+		}
+
+		if _, err := client.SetObject(ctx, &dsw.SetObjectRequest{Object: userObject(t, "morty", "student")}); err != nil {
+			return err
+		}
+
+		if _, err := client.DeleteRelation(ctx, deleteRelationRequest(memberRelation("council", "rick"))); err != nil {
+			return err
+		}
+
+		if _, err := client.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+			ObjectType: "group", ObjectId: "admins", WithRelations: true,
+		}); err != nil {
+			return err
+		}
+
+		_, err := client.SetRelation(ctx, &dsw.SetRelationRequest{Relation: memberRelation("council", "morty")})
+
+		return err
+	})
+	assert.Error(err)
+	assert.True(rec.RolledBack())
+
+	assert.Equal("scientist", dir.Objects["user:rick"].GetProperties().AsMap()["title"])
+	assert.NotContains(dir.Objects, "user:morty")
+	assert.Contains(dir.Objects, "group:admins")
+	assert.Contains(dir.Relations, directorytest.RelationKey(memberRelation("council", "rick")))
+	assert.Contains(dir.Relations, directorytest.RelationKey(memberRelation("admins", "rick")))
+	assert.Len(dir.Relations, 2)
+}
+
+func TestAtomicNested(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	client := newTestClient(dir)
+	errFailed := errors.New("failed")
+
+	err := client.Atomic(context.Background(), func(ctx context.Context) error {
+		if err := client.Atomic(ctx, func(ctx context.Context) error {
+			_, err := client.SetRelation(ctx, &dsw.SetRelationRequest{Relation: memberRelation("council", "rick")})
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return errFailed
+	})
+	assert.ErrorIs(err, errFailed)
+	assert.Empty(dir.Relations)
+
+	assert.NoError(client.Atomic(context.Background(), func(ctx context.Context) error {
+		_, err := client.SetRelation(ctx, &dsw.SetRelationRequest{Relation: memberRelation("council", "rick")})
+		return err
+	}))
+	assert.Len(dir.Relations, 1)
+}
+
+func TestReconcileSkipsRelationReads(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	client := newTestClient(dir)
+
+	dir.AddRelation(client.roleRelation("scientist", "rick"))
+	dir.AddRelation(memberRelation("council", "rick"))
+	dir.AddRelation(memberRelation("council", "morty"))
+
+	err := client.Atomic(context.Background(), func(ctx context.Context) error {
+		if err := client.SetRoleMembers(ctx, "scientist", []string{"morty"}); err != nil {
+			return err
+		}
+
+		relations, err := client.getGroupRelations(ctx, "council")
+		if err != nil {
+			return err
+		}
+
+		return client.removeStaleRelations(ctx, relations, []string{"morty"}, "council", zerolog.Nop())
+	})
+	assert.NoError(err)
+	assert.Zero(dir.RelationReads)
+
+	assert.Contains(dir.Relations, directorytest.RelationKey(client.roleRelation("scientist", "morty")))
+	assert.NotContains(dir.Relations, directorytest.RelationKey(client.roleRelation("scientist", "rick")))
+	assert.NotContains(dir.Relations, directorytest.RelationKey(memberRelation("council", "rick")))
+	assert.Len(dir.Relations, 2)
+
+	// The deleted relations are still journaled and restored.
+	dir.FailRelation = directorytest.RelationKey(memberRelation("council", "summer"))
+
+	err = client.Atomic(context.Background(), func(ctx context.Context) error {
+		if err := client.SetRoleMembers(ctx, "scientist", nil); err != nil {
+			return err
+		}
+
+		_, err := client.SetRelation(ctx, &dsw.SetRelationRequest{Relation: memberRelation("council", "summer")})
+
+		return err
+	})
+	assert.Error(err)
+	assert.Contains(dir.Relations, directorytest.RelationKey(client.roleRelation("scientist", "morty")))
+}
@@ -0,0 +1,113 @@
+package directory
+
+import (
+	"context"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/elimity-com/scim"
+)
+
+// Memberships indexes the group member relations of the directory by group and by member, as the
+// multi-valued "members" attribute of groups and the read-only "groups" attribute of users.
+type Memberships struct {
+	members map[string][]any
+	groups  map[string][]any
+}
+
+// GetMemberships reads the group member relations of the given group or user. If both ids are empty,
+// the memberships of all groups are read.
+func (s *Client) GetMemberships(ctx context.Context, groupID, userID string) (*Memberships, error) {
+	memberships := &Memberships{
+		members: make(map[string][]any),
+		groups:  make(map[string][]any),
+	}
+
+	if s.cfg.Group == nil {
+		return memberships, nil
+	}
+
+	req := &dsr.GetRelationsRequest{
+		ObjectType:  s.cfg.Group.ObjectType,
+		ObjectId:    groupID,
+		Relation:    s.cfg.Group.GroupMemberRelation,
+		WithObjects: true,
+	}
+
+	if userID != "" {
+		req.SubjectType = s.cfg.User.ObjectType
+		req.SubjectId = userID
+	}
+
+	relations, objects, err := s.getRelationsWithObjects(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, relation := range relations {
+		member := s.member(relation, objects)
+		if member == nil {
+			continue
+		}
+
+		memberships.members[relation.GetObjectId()] = append(memberships.members[relation.GetObjectId()], member)
+
+		if relation.GetSubjectType() == s.cfg.User.ObjectType {
+			memberships.groups[relation.GetSubjectId()] = append(memberships.groups[relation.GetSubjectId()], map[string]any{
+				"value":   relation.GetObjectId(),
+				"$ref":    "Groups/" + relation.GetObjectId(),
+				"display": displayName(objects, relation.GetObjectType(), relation.GetObjectId()),
+				"type":    "direct",
+			})
+		}
+	}
+
+	return memberships, nil
+}
+
+// SetMembers sets the "members" attribute of a group resource from the member relations of the group.
+func (m *Memberships) SetMembers(groupID string, attributes scim.ResourceAttributes) {
+	setMultiValued(attributes, "members", m.members[groupID])
+}
+
+// SetGroups sets the "groups" attribute of a user resource from the groups the user is a direct member of.
+func (m *Memberships) SetGroups(userID string, attributes scim.ResourceAttributes) {
+	setMultiValued(attributes, "groups", m.groups[userID])
+}
+
+func (s *Client) member(relation *dsc.Relation, objects map[string]*dsc.Object) map[string]any {
+	var memberType, endpoint string
+
+	switch relation.GetSubjectType() {
+	case s.cfg.User.ObjectType:
+		memberType, endpoint = "User", "Users/"
+	case s.cfg.Group.ObjectType:
+		memberType, endpoint = "Group", "Groups/"
+	default:
+		return nil
+	}
+
+	return map[string]any{
+		"value":   relation.GetSubjectId(),
+		"$ref":    endpoint + relation.GetSubjectId(),
+		"display": displayName(objects, relation.GetSubjectType(), relation.GetSubjectId()),
+		"type":    memberType,
+	}
+}
+
+func displayName(objects map[string]*dsc.Object, objectType, objectID string) string {
+	if object, ok := objects[objectType+":"+objectID]; ok && object.GetDisplayName() != "" {
+		return object.GetDisplayName()
+	}
+
+	return objectID
+}
+
+func setMultiValued(attributes scim.ResourceAttributes, name string, values []any) {
+	if len(values) == 0 {
+		delete(attributes, name)
+		return
+	}
+
+	attributes[name] = values
+}
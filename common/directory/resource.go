@@ -0,0 +1,124 @@
+package directory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/aserto-dev/ds-load/sdk/common/msg"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/elimity-com/scim"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetResource imports the objects and relations produced by the transform of a resource of a configured resource
+// type. Relations of the resource's directory object that the transform owns and no longer produces are deleted, and
+// relations written by others are left alone.
+func (s *Client) SetResource(
+	ctx context.Context,
+	resourceType *config.ResourceType,
+	resourceID string,
+	data *msg.Transform,
+) (scim.Meta, error) {
+	ctx, span := tracer.Start(ctx, "directory.SetResource", trace.WithAttributes(attribute.String("scim.id", resourceID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "SetResource").Str("type", resourceType.Name).Str("id", resourceID).Logger()
+	logger.Trace().Msg("set resource")
+
+	existingRelations, err := s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType: resourceType.ObjectType,
+		ObjectId:   resourceID,
+	})
+	if err != nil {
+		return scim.Meta{}, err
+	}
+
+	result, err := s.setObjects(ctx, data.GetObjects(), resourceType.ObjectType, logger)
+	if err != nil {
+		return result, err
+	}
+
+	for _, relation := range data.GetRelations() {
+		if slices.ContainsFunc(existingRelations, sameRelation(relation)) {
+			continue
+		}
+
+		logger.Trace().Any("relation", relation).Msg("setting relation")
+
+		if _, err := s.SetRelation(ctx, &dsw.SetRelationRequest{Relation: relation}); err != nil {
+			return result, err
+		}
+	}
+
+	for _, relation := range existingRelations {
+		if !ownsRelation(resourceType, data, relation) || slices.ContainsFunc(data.GetRelations(), sameRelation(relation)) {
+			continue
+		}
+
+		logger.Trace().Any("relation", relation).Msg("deleting relation")
+
+		if err := s.deleteRelation(ctx, relation); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteResource deletes the source object and the directory object of a resource of a configured resource type.
+func (s *Client) DeleteResource(ctx context.Context, resourceType *config.ResourceType, resourceID string) error {
+	ctx, span := tracer.Start(ctx, "directory.DeleteResource", trace.WithAttributes(attribute.String("scim.id", resourceID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "DeleteResource").Str("type", resourceType.Name).Str("id", resourceID).Logger()
+	logger.Trace().Msg("delete resource")
+
+	_, err := s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+		ObjectType:    resourceType.SourceObjectType,
+		ObjectId:      resourceID,
+		WithRelations: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+		ObjectType:    resourceType.ObjectType,
+		ObjectId:      resourceID,
+		WithRelations: true,
+	})
+
+	return err
+}
+
+// ownsRelation reports whether a relation of a resource's directory object is written by its transform, either
+// because the resource type declares the relation or because the transform produces the same relation and subject
+// type.
+func ownsRelation(resourceType *config.ResourceType, data *msg.Transform, relation *dsc.Relation) bool {
+	if slices.Contains(resourceType.Relations, relation.GetRelation()) {
+		return true
+	}
+
+	return slices.ContainsFunc(data.GetRelations(), func(r *dsc.Relation) bool {
+		return r.GetObjectType() == relation.GetObjectType() && r.GetObjectId() == relation.GetObjectId() &&
+			r.GetRelation() == relation.GetRelation() && r.GetSubjectType() == relation.GetSubjectType()
+	})
+}
+
+// deleteRelation deletes a relation read from the directory.
+func (s *Client) deleteRelation(ctx context.Context, relation *dsc.Relation) error {
+	_, err := s.unsetRelation(ctx, &dsw.DeleteRelationRequest{
+		ObjectType:      relation.GetObjectType(),
+		ObjectId:        relation.GetObjectId(),
+		Relation:        relation.GetRelation(),
+		SubjectType:     relation.GetSubjectType(),
+		SubjectId:       relation.GetSubjectId(),
+		SubjectRelation: relation.GetSubjectRelation(),
+	}, true)
+
+	return err
+}
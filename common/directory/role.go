@@ -0,0 +1,184 @@
+package directory
+
+import (
+	"context"
+	"slices"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GetRoleMembers reads the role relations of the given role, or of all roles if roleID is empty, and returns
+// the users holding each role as values of the multi-valued "members" attribute of roles.
+func (s *Client) GetRoleMembers(ctx context.Context, roleID string) (map[string][]any, error) {
+	members := make(map[string][]any)
+
+	relations, objects, err := s.getRelationsWithObjects(ctx, &dsr.GetRelationsRequest{
+		ObjectType:  s.cfg.Role.ObjectType,
+		ObjectId:    roleID,
+		Relation:    s.cfg.Role.RoleRelation,
+		SubjectType: s.cfg.User.ObjectType,
+		WithObjects: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, relation := range relations {
+		members[relation.GetObjectId()] = append(members[relation.GetObjectId()], map[string]any{
+			"value":   relation.GetSubjectId(),
+			"$ref":    "Users/" + relation.GetSubjectId(),
+			"display": displayName(objects, relation.GetSubjectType(), relation.GetSubjectId()),
+			"type":    "User",
+		})
+	}
+
+	return members, nil
+}
+
+// SetRoleMembers sets the role relations of a role to the given users, deleting the relations of users
+// that no longer hold the role. The "roles" attribute of the source objects of these users is updated too,
+// so that later updates of the users keep the role relations set here.
+func (s *Client) SetRoleMembers(ctx context.Context, roleID string, userIDs []string) error {
+	ctx, span := tracer.Start(ctx, "directory.SetRoleMembers", trace.WithAttributes(attribute.String("scim.id", roleID)))
+	defer span.End()
+
+	logger := s.logger.With().Ctx(ctx).Str("method", "SetRoleMembers").Str("id", roleID).Logger()
+
+	existingRelations, err := s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType:               s.cfg.Role.ObjectType,
+		ObjectId:                 roleID,
+		Relation:                 s.cfg.Role.RoleRelation,
+		SubjectType:              s.cfg.User.ObjectType,
+		WithEmptySubjectRelation: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	role, err := s.getObject(ctx, s.cfg.Role.ObjectType, roleID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		relation := s.roleRelation(roleID, userID)
+		if slices.ContainsFunc(existingRelations, sameRelation(relation)) {
+			continue
+		}
+
+		logger.Trace().Str("user", userID).Msg("setting role relation")
+
+		if _, err := s.setRelation(ctx, &dsw.SetRelationRequest{Relation: relation}, false); err != nil {
+			return err
+		}
+
+		if err := s.setUserRole(ctx, userID, roleID, role.GetDisplayName(), true); err != nil {
+			return err
+		}
+	}
+
+	for _, relation := range existingRelations {
+		if slices.Contains(userIDs, relation.GetSubjectId()) {
+			continue
+		}
+
+		logger.Trace().Str("user", relation.GetSubjectId()).Msg("deleting role relation")
+
+		if err := s.deleteRelation(ctx, relation); err != nil {
+			return err
+		}
+
+		if err := s.setUserRole(ctx, relation.GetSubjectId(), roleID, "", false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setUserRole adds a role to or removes it from the "roles" attribute of the source object of a user. Users
+// without a source object are skipped.
+func (s *Client) setUserRole(ctx context.Context, userID, roleID, display string, holds bool) error {
+	user, err := s.getObject(ctx, s.cfg.User.SourceObjectType, userID)
+	if err != nil || user == nil {
+		return err
+	}
+
+	properties := user.GetProperties().AsMap()
+	roles, _ := properties["roles"].([]any)
+
+	isRole := func(value any) bool {
+		role, _ := value.(map[string]any)
+		return role["value"] == roleID
+	}
+
+	if slices.ContainsFunc(roles, isRole) == holds {
+		return nil
+	}
+
+	if holds {
+		roles = append(roles, map[string]any{"value": roleID, "display": display, "type": "", "primary": false})
+	} else {
+		roles = slices.DeleteFunc(roles, isRole)
+	}
+
+	if len(roles) == 0 {
+		delete(properties, "roles")
+	} else {
+		properties["roles"] = roles
+	}
+
+	if user.Properties, err = structpb.NewStruct(properties); err != nil {
+		return err
+	}
+
+	_, err = s.SetObject(ctx, &dsw.SetObjectRequest{Object: user})
+
+	return err
+}
+
+// DeleteRole deletes a role object and its relations, and removes the role from the users holding it.
+func (s *Client) DeleteRole(ctx context.Context, roleID string) error {
+	if err := s.SetRoleMembers(ctx, roleID, nil); err != nil {
+		return err
+	}
+
+	_, err := s.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+		ObjectType:    s.cfg.Role.ObjectType,
+		ObjectId:      roleID,
+		WithRelations: true,
+	})
+
+	return err
+}
+
+// getUserRoleRelations returns the role relations of a user. Nil is returned if roles are not enabled, or if
+// role relations can't be told apart from group member relations, in which case they are not reconciled.
+func (s *Client) getUserRoleRelations(ctx context.Context, userID string) ([]*dsc.Relation, error) {
+	if !s.cfg.HasRoles() || s.cfg.Role.SharesGroupRelation(s.cfg.Group) {
+		return nil, nil
+	}
+
+	return s.getRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType:               s.cfg.Role.ObjectType,
+		Relation:                 s.cfg.Role.RoleRelation,
+		SubjectType:              s.cfg.User.ObjectType,
+		SubjectId:                userID,
+		WithEmptySubjectRelation: true,
+	})
+}
+
+func (s *Client) roleRelation(roleID, userID string) *dsc.Relation {
+	return &dsc.Relation{
+		ObjectType:  s.cfg.Role.ObjectType,
+		ObjectId:    roleID,
+		Relation:    s.cfg.Role.RoleRelation,
+		SubjectType: s.cfg.User.ObjectType,
+		SubjectId:   userID,
+	}
+}
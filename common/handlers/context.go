@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	cerr "github.com/aserto-dev/errors"
+	"github.com/aserto-dev/go-directory/pkg/derr"
+	"github.com/aserto-dev/scim/common/convert"
+	serrors "github.com/elimity-com/scim/errors"
+)
+
+// ErrPreconditionFailed is returned when the version of a resource doesn't match the If-Match request header.
+var ErrPreconditionFailed = serrors.ScimError{
+	Detail: "The resource version does not match the If-Match precondition.",
+	Status: http.StatusPreconditionFailed,
+}
+
+type ifMatchKey struct{}
+
+// WithIfMatch returns a context carrying the value of an If-Match request header.
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	if ifMatch == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ifMatchKey{}, ifMatch)
+}
+
+// HasIfMatch reports whether the request carries an If-Match precondition.
+func HasIfMatch(ctx context.Context) bool {
+	_, ok := ctx.Value(ifMatchKey{}).(string)
+	return ok
+}
+
+// CheckIfMatch returns ErrPreconditionFailed if the request carries an If-Match precondition
+// that isn't satisfied by the given directory object etag.
+func CheckIfMatch(ctx context.Context, etag string) error {
+	ifMatch, ok := ctx.Value(ifMatchKey{}).(string)
+	if !ok {
+		return nil
+	}
+
+	if !convert.MatchETag(ifMatch, etag) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// VersionConflict translates a directory etag mismatch, raised when an object changed between
+// reading and writing it, into ErrPreconditionFailed. Other errors are returned unchanged.
+func VersionConflict(err error) error {
+	if errors.Is(cerr.UnwrapAsertoError(err), derr.ErrHashMismatch) {
+		return ErrPreconditionFailed
+	}
+
+	return err
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the name of the authenticated caller of a request.
+func WithCaller(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, callerKey{}, name)
+}
+
+// Caller returns the name of the authenticated caller of a request, or an empty string if the request
+// isn't authenticated.
+func Caller(ctx context.Context) string {
+	name, _ := ctx.Value(callerKey{}).(string)
+	return name
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/scim2/filter-parser/v2"
+)
+
+// ReferencesAttribute reports whether a filter expression refers to the top-level attribute of the given name.
+func ReferencesAttribute(expr filter.Expression, name string) bool {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		return strings.EqualFold(e.AttributePath.AttributeName, name)
+	case *filter.ValuePath:
+		return strings.EqualFold(e.AttributePath.AttributeName, name)
+	case *filter.LogicalExpression:
+		return ReferencesAttribute(e.Left, name) || ReferencesAttribute(e.Right, name)
+	case *filter.NotExpression:
+		return ReferencesAttribute(e.Expression, name)
+	default:
+		return false
+	}
+}
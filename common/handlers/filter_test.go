@@ -0,0 +1,35 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/scim2/filter-parser/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferencesAttribute(t *testing.T) {
+	tests := []struct {
+		filter  string
+		members bool
+		groups  bool
+	}{
+		{filter: `displayName eq "council"`},
+		{filter: `externalId eq "00g1" or displayName sw "c"`},
+		{filter: `members.value eq "rick"`, members: true},
+		{filter: `members[value eq "rick"]`, members: true},
+		{filter: `displayName eq "council" and not (members pr)`, members: true},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:Group:members.value eq "rick"`, members: true},
+		{filter: `userName eq "rick" and groups[display eq "council"]`, groups: true},
+		{filter: `groups.value eq "council"`, groups: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.filter, func(t *testing.T) {
+			expr, err := filter.ParseFilter([]byte(tc.filter))
+			require.NoError(t, err)
+			require.Equal(t, tc.members, handlers.ReferencesAttribute(expr, "members"))
+			require.Equal(t, tc.groups, handlers.ReferencesAttribute(expr, "groups"))
+		})
+	}
+}
@@ -3,11 +3,14 @@ package groups
 import (
 	"context"
 
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
 	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/aserto-dev/scim/common/model"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
 )
 
 func (g GroupResourceHandler) Create(ctx context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
@@ -16,10 +19,15 @@ func (g GroupResourceHandler) Create(ctx context.Context, attributes scim.Resour
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	logger := g.logger.With().Str("method", "Create").Str("name", groupName).Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "Create").Str("name", groupName).Logger()
 	logger.Info().Msg("create group")
 	logger.Trace().Any("attributes", attributes).Msg("creating group")
 
+	if err := g.validator.Validate(attributes); err != nil {
+		logger.Err(err).Msg("invalid group")
+		return scim.Resource{}, err
+	}
+
 	group := &model.Group{}
 
 	if err := convert.Unmarshal(attributes, group); err != nil {
@@ -27,39 +35,72 @@ func (g GroupResourceHandler) Create(ctx context.Context, attributes scim.Resour
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	var result scim.Resource
-
 	converter := convert.NewConverter(g.cfg)
 
+	_, convertSpan := handlers.StartSpan(ctx, "convert.SCIMGroupToObject")
 	object, err := converter.SCIMGroupToObject(group)
+
+	convertSpan.End()
+
 	if err != nil {
 		logger.Err(err).Msg("failed to convert group to object")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	sourceGroupResp, err := g.dirClient.DS().Writer.SetObject(ctx, &dsw.SetObjectRequest{
-		Object: object,
-	})
-	if err != nil {
-		logger.Err(err).Msg("failed to create group")
-		return scim.Resource{}, err
-	}
-
-	transformResult, err := converter.TransformResource(attributes, sourceGroupResp.GetResult().GetId(), "group")
-	if err != nil {
-		logger.Err(err).Msg("failed to transform group")
+	if err := converter.SetExtensionProperties(object, attributes, g.cfg.Group.SchemaExtensions); err != nil {
+		logger.Err(err).Msg("failed to convert group extensions")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	meta, err := g.dirClient.SetGroup(ctx, sourceGroupResp.GetResult().GetId(), transformResult)
+	result, err := g.setGroup(ctx, object, attributes, converter, logger)
 	if err != nil {
-		logger.Err(err).Msg("failed to sync group")
 		return scim.Resource{}, err
 	}
 
-	result = converter.ObjectToResource(sourceGroupResp.GetResult(), meta)
-
 	logger.Trace().Any("response", result).Msg("group created")
 
 	return result, nil
 }
+
+// setGroup writes the group source object and syncs the transformed group objects and member relations.
+// The writes are rolled back if any of them fails.
+func (g GroupResourceHandler) setGroup(
+	ctx context.Context,
+	object *dsc.Object,
+	attributes scim.ResourceAttributes,
+	converter *convert.Converter,
+	logger zerolog.Logger,
+) (scim.Resource, error) {
+	var result scim.Resource
+
+	err := g.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		sourceGroupResp, err := g.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
+			Object: object,
+		})
+		if err != nil {
+			logger.Err(err).Msg("failed to set group")
+			return handlers.VersionConflict(err)
+		}
+
+		_, transformSpan := handlers.StartSpan(ctx, "convert.TransformResource")
+		transformResult, err := converter.TransformResource(attributes, sourceGroupResp.GetResult().GetId(), "group")
+
+		transformSpan.End()
+
+		if err != nil {
+			logger.Err(err).Msg("failed to transform group")
+			return serrors.ScimErrorInvalidSyntax
+		}
+
+		if _, err := g.dirClient.SetGroup(ctx, sourceGroupResp.GetResult().GetId(), transformResult); err != nil {
+			logger.Err(err).Msg("failed to sync group")
+			return err
+		}
+
+		result = converter.ObjectToResource(sourceGroupResp.GetResult(), convert.ObjectMeta(sourceGroupResp.GetResult()))
+
+		return nil
+	})
+
+	return result, err
+}
@@ -3,10 +3,17 @@ package groups
 import "context"
 
 func (g GroupResourceHandler) Delete(ctx context.Context, id string) error {
-	logger := g.logger.With().Str("method", "Delete").Str("id", id).Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "Delete").Str("id", id).Logger()
 	logger.Info().Msg("delete group")
 
-	err := g.dirClient.DeleteGroup(ctx, id)
+	if err := g.checkIfMatch(ctx, id); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return err
+	}
+
+	err := g.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		return g.dirClient.DeleteGroup(ctx, id)
+	})
 	if err != nil {
 		logger.Err(err).Msg("failed to delete group")
 		return err
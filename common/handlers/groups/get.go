@@ -2,16 +2,19 @@ package groups
 
 import (
 	"context"
+	"errors"
 
 	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
 	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
 )
 
 func (g GroupResourceHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
-	logger := g.logger.With().Str("method", "Get").Str("id", id).Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "Get").Str("id", id).Logger()
 	logger.Info().Msg("get group")
 
 	if !g.cfg.HasGroups() {
@@ -29,58 +32,110 @@ func (g GroupResourceHandler) Get(ctx context.Context, id string) (scim.Resource
 		return scim.Resource{}, err
 	}
 
+	memberships, err := g.dirClient.GetMemberships(ctx, id, "")
+	if err != nil {
+		logger.Err(err).Msg("failed to get group members")
+		return scim.Resource{}, err
+	}
+
 	converter := convert.NewConverter(g.cfg)
 
-	createdAt := resp.GetResult().GetCreatedAt().AsTime()
-	updatedAt := resp.GetResult().GetUpdatedAt().AsTime()
-	resource := converter.ObjectToResource(resp.GetResult(), scim.Meta{
-		Created:      &createdAt,
-		LastModified: &updatedAt,
-		Version:      resp.GetResult().GetEtag(),
-	})
+	resource := converter.ObjectToResource(resp.GetResult(), convert.ObjectMeta(resp.GetResult()))
+	memberships.SetMembers(id, resource.Attributes)
 
 	return resource, nil
 }
 
 func (g GroupResourceHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
-	logger := g.logger.With().Str("method", "GetAll").Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "GetAll").Logger()
 	logger.Info().Msg("getting all groups")
 
-	resources := make([]scim.Resource, 0)
-
 	if !g.cfg.HasGroups() {
 		logger.Error().Msg("groups not enabled")
 		return scim.Page{}, serrors.ScimErrorBadRequest("groups not enabled")
 	}
 
-	resp, err := g.dirClient.DS().Reader.GetObjects(ctx, &dsr.GetObjectsRequest{
-		ObjectType: g.cfg.Group.SourceObjectType,
-		Page: &dsc.PaginationRequest{
-			Size: int32(params.Count), //nolint:gosec
-		},
+	if params.FilterValidator == nil || !handlers.ReferencesAttribute(params.FilterValidator.GetFilter(), "members") {
+		return g.getPage(ctx, params, logger)
+	}
+
+	// The filter needs the members of every group, so all the group member relations are read.
+	memberships, err := g.dirClient.GetMemberships(ctx, "", "")
+	if err != nil {
+		logger.Err(err).Msg("failed to read group members")
+		return scim.Page{}, err
+	}
+
+	converter := convert.NewConverter(g.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
+
+	err = g.dirClient.ForEachObject(ctx, g.cfg.Group.SourceObjectType, func(object *dsc.Object) error {
+		resource := converter.ObjectToResource(object, convert.ObjectMeta(object))
+		memberships.SetMembers(object.GetId(), resource.Attributes)
+
+		if params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
 	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to read groups")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
 	if err != nil {
 		logger.Err(err).Msg("failed to read groups")
 		return scim.Page{}, err
 	}
 
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("groups read")
+
+	return result, nil
+}
+
+// getPage lists the groups matching a filter that doesn't reference their members, if any, and reads the members
+// of the groups in the page only, rather than all the group member relations.
+func (g GroupResourceHandler) getPage(
+	ctx context.Context,
+	params scim.ListRequestParams,
+	logger zerolog.Logger,
+) (scim.Page, error) {
 	converter := convert.NewConverter(g.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
+
+	err := g.dirClient.ForEachObject(ctx, g.cfg.Group.SourceObjectType, func(object *dsc.Object) error {
+		resource := converter.ObjectToResource(object, convert.ObjectMeta(object))
+
+		if params.FilterValidator == nil || params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to read groups")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to read groups")
+		return scim.Page{}, err
+	}
+
+	for _, resource := range result.Resources {
+		memberships, err := g.dirClient.GetMemberships(ctx, resource.ID, "")
+		if err != nil {
+			logger.Err(err).Str("id", resource.ID).Msg("failed to read group members")
+			return scim.Page{}, err
+		}
 
-	for _, v := range resp.GetResults() {
-		createdAt := v.GetCreatedAt().AsTime()
-		updatedAt := v.GetUpdatedAt().AsTime()
-		resource := converter.ObjectToResource(v, scim.Meta{
-			Created:      &createdAt,
-			LastModified: &updatedAt,
-			Version:      v.GetEtag(),
-		})
-		resources = append(resources, resource)
+		memberships.SetMembers(resource.ID, resource.Attributes)
 	}
 
-	logger.Trace().Int("total_results", len(resources)).Msg("groups read")
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("groups read")
 
-	return scim.Page{
-		TotalResults: len(resources),
-		Resources:    resources,
-	}, nil
+	return result, nil
 }
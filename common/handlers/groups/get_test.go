@@ -0,0 +1,69 @@
+package groups
+
+import (
+	"context"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/elimity-com/scim"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// relationsRecorder records the relation reads of a directory.
+type relationsRecorder struct {
+	*directorytest.Directory
+
+	requests []*dsr.GetRelationsRequest
+}
+
+func (r *relationsRecorder) GetRelations(
+	ctx context.Context,
+	in *dsr.GetRelationsRequest,
+	opts ...grpc.CallOption,
+) (*dsr.GetRelationsResponse, error) {
+	r.requests = append(r.requests, in)
+	return r.Directory.GetRelations(ctx, in, opts...)
+}
+
+func TestGetAllReadsMembersOfPage(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	recorder := &relationsRecorder{Directory: dir}
+
+	cfg := &convert.TransformConfig{Config: &config.Config{
+		User:  &config.User{ObjectType: "user"},
+		Group: &config.Group{ObjectType: "group", GroupMemberRelation: "member", SourceObjectType: "scim.2.0.group"},
+	}}
+
+	logger := zerolog.Nop()
+	handler, err := NewGroupResourceHandler(&logger, cfg, dir.Client(), nil)
+	assert.NoError(err)
+
+	handler.dirClient.DS().Reader = recorder
+
+	for _, id := range []string{"admins", "council", "staff"} {
+		properties, err := structpb.NewStruct(map[string]any{"displayName": id})
+		assert.NoError(err)
+
+		dir.AddObject(&dsc.Object{Type: "scim.2.0.group", Id: id, Properties: properties})
+		dir.AddRelation(&dsc.Relation{ObjectType: "group", ObjectId: id, Relation: "member", SubjectType: "user", SubjectId: "rick"})
+	}
+
+	page, err := handler.GetAll(context.Background(), scim.ListRequestParams{StartIndex: 2, Count: 1})
+	assert.NoError(err)
+	assert.Equal(3, page.TotalResults)
+	assert.Len(page.Resources, 1)
+	assert.Equal("council", page.Resources[0].ID)
+	assert.Len(page.Resources[0].Attributes["members"], 1)
+
+	assert.Len(recorder.requests, 1)
+	assert.Equal("council", recorder.requests[0].GetObjectId())
+}
@@ -1,21 +1,30 @@
 package groups
 
 import (
+	"context"
+
 	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
 	"github.com/aserto-dev/scim/common/convert"
 	"github.com/aserto-dev/scim/common/directory"
+	"github.com/aserto-dev/scim/common/handlers"
+	serrors "github.com/elimity-com/scim/errors"
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GroupResourceHandler struct {
 	cfg       *convert.TransformConfig
 	logger    *zerolog.Logger
 	dirClient *directory.Client
+	validator *handlers.Validator
 }
 
 func NewGroupResourceHandler(logger *zerolog.Logger,
 	cfg *convert.TransformConfig,
 	dsClient *ds.Client,
+	validator *handlers.Validator,
 ) (*GroupResourceHandler, error) {
 	groupLogger := logger.With().Str("component", "groups-handler").Logger()
 	dirClient := directory.NewDirectoryClient(cfg, &groupLogger, dsClient)
@@ -24,5 +33,27 @@ func NewGroupResourceHandler(logger *zerolog.Logger,
 		cfg:       cfg,
 		logger:    &groupLogger,
 		dirClient: dirClient,
+		validator: validator,
 	}, nil
 }
+
+// checkIfMatch verifies the If-Match precondition of the request, if any, against the current version of the group.
+func (g GroupResourceHandler) checkIfMatch(ctx context.Context, id string) error {
+	if !handlers.HasIfMatch(ctx) {
+		return nil
+	}
+
+	resp, err := g.dirClient.DS().Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType: g.cfg.Group.SourceObjectType,
+		ObjectId:   id,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return serrors.ScimErrorResourceNotFound(id)
+		}
+
+		return err
+	}
+
+	return handlers.CheckIfMatch(ctx, resp.GetResult().GetEtag())
+}
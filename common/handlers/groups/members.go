@@ -0,0 +1,192 @@
+package groups
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/scim2/filter-parser/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const membersAttribute = "members"
+
+// memberChange is a PATCH operation that adds members to or removes members from a group.
+type memberChange struct {
+	add bool
+	ids []string
+}
+
+// memberChanges translates PATCH operations into member changes. It reports false if any of the
+// operations does something other than adding or removing individual members.
+func memberChanges(operations []scim.PatchOperation) ([]memberChange, bool) {
+	changes := make([]memberChange, 0, len(operations))
+
+	for _, op := range operations {
+		if !isMembersPath(op.Path) {
+			return nil, false
+		}
+
+		var ids []string
+
+		switch {
+		case op.Op == scim.PatchOperationAdd:
+			ids = memberValues(op.Value)
+		case op.Op == scim.PatchOperationRemove && op.Path.ValueExpression != nil:
+			ids = memberFilterValue(op.Path.ValueExpression)
+		case op.Op == scim.PatchOperationRemove:
+			ids = memberValues(op.Value)
+		}
+
+		if len(ids) == 0 {
+			return nil, false
+		}
+
+		changes = append(changes, memberChange{add: op.Op == scim.PatchOperationAdd, ids: ids})
+	}
+
+	return changes, true
+}
+
+func isMembersPath(path *filter.Path) bool {
+	if path == nil || path.SubAttribute != nil || path.AttributePath.SubAttribute != nil {
+		return false
+	}
+
+	if uri := path.AttributePath.URI(); uri != "" && uri != schema.GroupSchema {
+		return false
+	}
+
+	return strings.EqualFold(path.AttributePath.AttributeName, membersAttribute)
+}
+
+// memberValues returns the member ids of a value like [{"value": "id"}].
+func memberValues(value any) []string {
+	var members []any
+
+	switch v := value.(type) {
+	case []any:
+		members = v
+	case map[string]any:
+		members = []any{v}
+	default:
+		return nil
+	}
+
+	ids := make([]string, 0, len(members))
+
+	for _, member := range members {
+		m, ok := member.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		id, ok := m["value"].(string)
+		if !ok || id == "" {
+			return nil
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// memberFilterValue returns the member id of a value filter like members[value eq "id"].
+func memberFilterValue(expr filter.Expression) []string {
+	attrExpr, ok := expr.(*filter.AttributeExpression)
+	if !ok || attrExpr.Operator != filter.EQ || !strings.EqualFold(attrExpr.AttributePath.AttributeName, "value") {
+		return nil
+	}
+
+	id, ok := attrExpr.CompareValue.(string)
+	if !ok || id == "" {
+		return nil
+	}
+
+	return []string{id}
+}
+
+// patchMembers applies member changes as individual relation writes and updates the members stored on the
+// group source object incrementally, without transforming the group again.
+func (g GroupResourceHandler) patchMembers(
+	ctx context.Context,
+	groupObj *dsc.Object,
+	changes []memberChange,
+	converter *convert.Converter,
+	logger zerolog.Logger,
+) (scim.Resource, error) {
+	attr := converter.ObjectToResourceAttributes(groupObj)
+	members, _ := attr[membersAttribute].([]any)
+
+	for _, change := range changes {
+		for _, id := range change.ids {
+			var err error
+
+			if change.add {
+				logger.Trace().Str("member", id).Msg("adding member")
+				err = g.dirClient.AddGroupMember(ctx, groupObj.GetId(), id)
+				members = addMember(members, id)
+			} else {
+				logger.Trace().Str("member", id).Msg("removing member")
+				err = g.dirClient.RemoveGroupMember(ctx, groupObj.GetId(), id)
+				members = removeMember(members, id)
+			}
+
+			if err != nil {
+				logger.Err(err).Str("member", id).Msg("failed to update member")
+				return scim.Resource{}, err
+			}
+		}
+	}
+
+	if len(members) == 0 {
+		delete(attr, membersAttribute)
+	} else {
+		attr[membersAttribute] = members
+	}
+
+	props, err := structpb.NewStruct(attr)
+	if err != nil {
+		logger.Err(err).Msg("failed to convert attributes to struct")
+		return scim.Resource{}, err
+	}
+
+	groupObj.Properties = props
+
+	sourceGroupResp, err := g.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
+		Object: groupObj,
+	})
+	if err != nil {
+		logger.Err(err).Msg("failed to update group members")
+		return scim.Resource{}, handlers.VersionConflict(err)
+	}
+
+	return converter.ObjectToResource(sourceGroupResp.GetResult(), convert.ObjectMeta(sourceGroupResp.GetResult())), nil
+}
+
+func addMember(members []any, id string) []any {
+	if slices.ContainsFunc(members, isMember(id)) {
+		return members
+	}
+
+	return append(members, map[string]any{"value": id})
+}
+
+func removeMember(members []any, id string) []any {
+	return slices.DeleteFunc(members, isMember(id))
+}
+
+func isMember(id string) func(any) bool {
+	return func(member any) bool {
+		m, ok := member.(map[string]any)
+		return ok && m["value"] == id
+	}
+}
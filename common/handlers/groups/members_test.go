@@ -0,0 +1,74 @@
+package groups
+
+import (
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/scim2/filter-parser/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      string
+		path    string
+		value   any
+		changes []memberChange
+		ok      bool
+	}{
+		{
+			name:    "add members",
+			op:      scim.PatchOperationAdd,
+			path:    "members",
+			value:   []any{map[string]any{"value": "rick"}, map[string]any{"value": "morty"}},
+			changes: []memberChange{{add: true, ids: []string{"rick", "morty"}}},
+			ok:      true,
+		},
+		{
+			name:    "remove member by filter",
+			op:      scim.PatchOperationRemove,
+			path:    `members[value eq "rick"]`,
+			changes: []memberChange{{add: false, ids: []string{"rick"}}},
+			ok:      true,
+		},
+		{
+			name:    "remove members by value",
+			op:      scim.PatchOperationRemove,
+			path:    "members",
+			value:   []any{map[string]any{"value": "rick"}},
+			changes: []memberChange{{add: false, ids: []string{"rick"}}},
+			ok:      true,
+		},
+		{
+			name: "remove all members",
+			op:   scim.PatchOperationRemove,
+			path: "members",
+		},
+		{
+			name:  "replace members",
+			op:    scim.PatchOperationReplace,
+			path:  "members",
+			value: []any{map[string]any{"value": "rick"}},
+		},
+		{
+			name:  "other attribute",
+			op:    scim.PatchOperationReplace,
+			path:  "displayName",
+			value: "admins",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			path, err := filter.ParsePath([]byte(tc.path))
+			assert.NoError(err)
+
+			changes, ok := memberChanges([]scim.PatchOperation{{Op: tc.op, Path: &path, Value: tc.value}})
+			assert.Equal(tc.ok, ok)
+			assert.Equal(tc.changes, changes)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
 	"github.com/aserto-dev/scim/common"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
 	"github.com/rs/zerolog"
@@ -17,7 +18,7 @@ import (
 )
 
 func (g GroupResourceHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
-	logger := g.logger.With().Str("method", "Patch").Str("id", id).Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "Patch").Str("id", id).Logger()
 	logger.Info().Msg("patch group")
 
 	if !g.cfg.HasGroups() {
@@ -41,33 +42,50 @@ func (g GroupResourceHandler) Patch(ctx context.Context, id string, operations [
 		return scim.Resource{}, err
 	}
 
+	if err := handlers.CheckIfMatch(ctx, getObjResp.GetResult().GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
 	converter := convert.NewConverter(g.cfg)
-	attr := converter.ObjectToResourceAttributes(getObjResp.GetResult())
-
-	for _, op := range operations {
-		switch op.Op {
-		case scim.PatchOperationAdd:
-			attr, err = common.HandlePatchOPAdd(attr, op)
-			if err != nil {
-				logger.Err(err).Msg("error adding property")
-				return scim.Resource{}, err
-			}
-		case scim.PatchOperationRemove:
-			attr, err = common.HandlePatchOPRemove(attr, op)
-			if err != nil {
-				logger.Err(err).Msg("error removing property")
-				return scim.Resource{}, err
-			}
-		case scim.PatchOperationReplace:
-			attr, err = common.HandlePatchOPReplace(attr, op)
-			if err != nil {
-				logger.Err(err).Msg("error replacing property")
-				return scim.Resource{}, err
-			}
+
+	if changes, ok := memberChanges(operations); ok {
+		logger.Trace().Int("changes", len(changes)).Msg("patching group members")
+
+		var resource scim.Resource
+
+		err = g.dirClient.Atomic(ctx, func(ctx context.Context) error {
+			resource, err = g.patchMembers(ctx, getObjResp.GetResult(), changes, converter, logger)
+			return err
+		})
+		if err != nil {
+			return scim.Resource{}, err
 		}
+
+		logger.Trace().Any("response", resource).Msg("group members patched")
+
+		return resource, nil
+	}
+
+	current := converter.ObjectToResourceAttributes(getObjResp.GetResult())
+
+	attr, err := common.ApplyPatch(current, operations, g.validator.CaseExact)
+	if err != nil {
+		logger.Err(err).Msg("failed to apply operations")
+		return scim.Resource{}, err
+	}
+
+	if err := g.validator.ValidateUpdate(current, attr); err != nil {
+		logger.Err(err).Msg("patched group is invalid")
+		return scim.Resource{}, err
 	}
 
-	resource, err := g.updateGroup(ctx, attr, getObjResp.GetResult(), converter, logger)
+	var resource scim.Resource
+
+	err = g.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		resource, err = g.updateGroup(ctx, attr, getObjResp.GetResult(), converter, logger)
+		return err
+	})
 	if err != nil {
 		return scim.Resource{}, err
 	}
@@ -84,7 +102,11 @@ func (g GroupResourceHandler) updateGroup(
 	converter *convert.Converter,
 	logger zerolog.Logger,
 ) (scim.Resource, error) {
+	_, transformSpan := handlers.StartSpan(ctx, "convert.TransformResource")
 	transformResult, err := converter.TransformResource(attr, groupObj.GetId(), "group")
+
+	transformSpan.End()
+
 	if err != nil {
 		logger.Err(err).Msg("failed to convert group to object")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
@@ -98,19 +120,18 @@ func (g GroupResourceHandler) updateGroup(
 
 	groupObj.Properties = props
 
-	sourceGroupResp, err := g.dirClient.DS().Writer.SetObject(ctx, &dsw.SetObjectRequest{
+	sourceGroupResp, err := g.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
 		Object: groupObj,
 	})
 	if err != nil {
 		logger.Err(err).Msg("failed to replace group")
-		return scim.Resource{}, err
+		return scim.Resource{}, handlers.VersionConflict(err)
 	}
 
-	meta, err := g.dirClient.SetGroup(ctx, groupObj.GetId(), transformResult)
-	if err != nil {
+	if _, err := g.dirClient.SetGroup(ctx, groupObj.GetId(), transformResult); err != nil {
 		logger.Err(err).Msg("failed to sync group")
 		return scim.Resource{}, err
 	}
 
-	return converter.ObjectToResource(sourceGroupResp.GetResult(), meta), nil
+	return converter.ObjectToResource(sourceGroupResp.GetResult(), convert.ObjectMeta(sourceGroupResp.GetResult())), nil
 }
@@ -3,22 +3,83 @@ package groups
 import (
 	"context"
 
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/common/model"
 	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Replace updates the group in place. Only the source object and the member relations of the group are
+// reconciled, relations in which the group is the subject are left untouched.
 func (g GroupResourceHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
-	logger := g.logger.With().Str("method", "Replace").Str("id", id).Logger()
+	logger := g.logger.With().Ctx(ctx).Str("method", "Replace").Str("id", id).Logger()
 	logger.Info().Msg("replace group")
+	logger.Trace().Any("attributes", attributes).Msg("replacing group")
 
-	err := g.Delete(ctx, id)
+	if !g.cfg.HasGroups() {
+		logger.Error().Msg("groups not enabled")
+		return scim.Resource{}, serrors.ScimErrorBadRequest("groups not enabled")
+	}
+
+	getObjResp, err := g.dirClient.DS().Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType:    g.cfg.Group.SourceObjectType,
+		ObjectId:      id,
+		WithRelations: false,
+	})
 	if err != nil {
-		logger.Err(err).Msg("failed to delete group")
+		logger.Err(err).Msg("failed to get group")
+
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.NotFound {
+			return scim.Resource{}, serrors.ScimErrorResourceNotFound(id)
+		}
+
+		return scim.Resource{}, err
+	}
+
+	if err := handlers.CheckIfMatch(ctx, getObjResp.GetResult().GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	converter := convert.NewConverter(g.cfg)
+
+	if err := g.validator.ValidateUpdate(converter.ObjectToResourceAttributes(getObjResp.GetResult()), attributes); err != nil {
+		logger.Err(err).Msg("invalid group")
 		return scim.Resource{}, err
 	}
 
-	resource, err := g.Create(ctx, attributes)
+	group := &model.Group{}
+
+	if err := convert.Unmarshal(attributes, group); err != nil {
+		logger.Err(err).Msg("failed to convert attributes to group")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	_, convertSpan := handlers.StartSpan(ctx, "convert.SCIMGroupToObject")
+	object, err := converter.SCIMGroupToObject(group)
+
+	convertSpan.End()
+
+	if err != nil {
+		logger.Err(err).Msg("failed to convert group to object")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	if err := converter.SetExtensionProperties(object, attributes, g.cfg.Group.SchemaExtensions); err != nil {
+		logger.Err(err).Msg("failed to convert group extensions")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	object.Id = getObjResp.GetResult().GetId()
+	object.Etag = getObjResp.GetResult().GetEtag()
+
+	resource, err := g.setGroup(ctx, object, attributes, converter, logger)
 	if err != nil {
-		logger.Err(err).Msg("failed to create group")
 		return scim.Resource{}, err
 	}
 
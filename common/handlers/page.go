@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elimity-com/scim"
+)
+
+// ErrPageComplete is returned by PageBuilder.Add when the resources of the caller are restricted and the page
+// is complete, so that no more resources need to be listed.
+var ErrPageComplete = errors.New("page complete")
+
+// readableBatchSize is the number of resources whose readability is checked at once.
+const readableBatchSize = 100
+
+// ReadableFunc returns the ids of the resources that the caller of a request may read.
+type ReadableFunc func(ctx context.Context, ids []string) (map[string]bool, error)
+
+type readableKey struct{}
+
+// WithReadable returns a context restricting the resources listed in the response to a request to those
+// for which readable returns true.
+func WithReadable(ctx context.Context, readable ReadableFunc) context.Context {
+	return context.WithValue(ctx, readableKey{}, readable)
+}
+
+// PageBuilder assembles the page of a SCIM list response as defined in RFC 7644 section 3.4.2.4.
+// Every resource added counts towards the total number of results, but only the resources
+// between the 1-based start index and the requested count are kept.
+//
+// When the context restricts the resources that can be read, resources are checked in batches and the
+// others are left out. The page is then complete as soon as a readable resource follows the requested
+// window, and its total is one more than the end of the window.
+type PageBuilder struct {
+	ctx        context.Context
+	readable   ReadableFunc
+	startIndex int
+	count      int
+	total      int
+	resources  []scim.Resource
+	pending    []scim.Resource
+	complete   bool
+}
+
+func NewPageBuilder(ctx context.Context, params scim.ListRequestParams) *PageBuilder {
+	readable, _ := ctx.Value(readableKey{}).(ReadableFunc)
+
+	return &PageBuilder{
+		ctx:        ctx,
+		readable:   readable,
+		startIndex: max(params.StartIndex, 1),
+		count:      max(params.Count, 0),
+		resources:  make([]scim.Resource, 0),
+	}
+}
+
+// Add counts a resource matching the request and keeps it when it falls within the requested window.
+// It returns ErrPageComplete once the page is complete, or the error of checking whether resources
+// can be read.
+func (p *PageBuilder) Add(resource scim.Resource) error {
+	if p.complete {
+		return ErrPageComplete
+	}
+
+	if p.readable == nil {
+		p.add(resource)
+		return nil
+	}
+
+	p.pending = append(p.pending, resource)
+	if len(p.pending) < readableBatchSize {
+		return nil
+	}
+
+	return p.flush()
+}
+
+func (p *PageBuilder) Page() (scim.Page, error) {
+	if err := p.flush(); err != nil && !errors.Is(err, ErrPageComplete) {
+		return scim.Page{}, err
+	}
+
+	total := p.total
+	if p.complete {
+		total++
+	}
+
+	return scim.Page{
+		TotalResults: total,
+		Resources:    p.resources,
+	}, nil
+}
+
+func (p *PageBuilder) add(resource scim.Resource) {
+	p.total++
+
+	if p.total >= p.startIndex && len(p.resources) < p.count {
+		p.resources = append(p.resources, resource)
+	}
+}
+
+// flush adds the pending resources that can be read, up to the first one that follows the window.
+func (p *PageBuilder) flush() error {
+	if len(p.pending) == 0 || p.complete {
+		return nil
+	}
+
+	ids := make([]string, 0, len(p.pending))
+	for _, resource := range p.pending {
+		ids = append(ids, resource.ID)
+	}
+
+	readable, err := p.readable(p.ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	pending := p.pending
+	p.pending = nil
+
+	for _, resource := range pending {
+		if !readable[resource.ID] {
+			continue
+		}
+
+		if p.count > 0 && p.total >= p.startIndex-1+p.count {
+			p.complete = true
+			return ErrPageComplete
+		}
+
+		p.add(resource)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageBuilder(t *testing.T) {
+	tests := []struct {
+		name       string
+		startIndex int
+		count      int
+		ids        []string
+	}{
+		{name: "first page", startIndex: 1, count: 2, ids: []string{"1", "2"}},
+		{name: "second page", startIndex: 3, count: 2, ids: []string{"3", "4"}},
+		{name: "last partial page", startIndex: 5, count: 2, ids: []string{"5"}},
+		{name: "past the end", startIndex: 10, count: 2, ids: []string{}},
+		{name: "count zero", startIndex: 1, count: 0, ids: []string{}},
+		{name: "start index below one", startIndex: 0, count: 1, ids: []string{"1"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			page := handlers.NewPageBuilder(context.Background(), scim.ListRequestParams{StartIndex: tc.startIndex, Count: tc.count})
+			for i := 1; i <= 5; i++ {
+				assert.NoError(page.Add(scim.Resource{ID: strconv.Itoa(i)}))
+			}
+
+			result, err := page.Page()
+			assert.NoError(err)
+			assert.Equal(5, result.TotalResults)
+
+			ids := make([]string, 0, len(result.Resources))
+			for _, resource := range result.Resources {
+				ids = append(ids, resource.ID)
+			}
+
+			assert.Equal(tc.ids, ids)
+		})
+	}
+}
+
+func TestPageBuilderReadable(t *testing.T) {
+	// Only the even ids are readable.
+	readable := func(_ context.Context, ids []string) (map[string]bool, error) {
+		result := map[string]bool{}
+
+		for _, id := range ids {
+			if n, _ := strconv.Atoi(id); n%2 == 0 {
+				result[id] = true
+			}
+		}
+
+		return result, nil
+	}
+
+	tests := []struct {
+		name       string
+		startIndex int
+		count      int
+		ids        []string
+		total      int
+	}{
+		{name: "first page", startIndex: 1, count: 2, ids: []string{"2", "4"}, total: 3},
+		{name: "last page", startIndex: 4, count: 2, ids: []string{"8", "10"}, total: 5},
+		{name: "past the end", startIndex: 10, count: 2, ids: []string{}, total: 5},
+		{name: "count zero", startIndex: 1, count: 0, ids: []string{}, total: 5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			ctx := handlers.WithReadable(context.Background(), readable)
+			page := handlers.NewPageBuilder(ctx, scim.ListRequestParams{StartIndex: tc.startIndex, Count: tc.count})
+
+			for i := 1; i <= 10; i++ {
+				assert.NoError(page.Add(scim.Resource{ID: strconv.Itoa(i)}))
+			}
+
+			result, err := page.Page()
+			assert.NoError(err)
+			assert.Equal(tc.total, result.TotalResults)
+
+			ids := make([]string, 0, len(result.Resources))
+			for _, resource := range result.Resources {
+				ids = append(ids, resource.ID)
+			}
+
+			assert.Equal(tc.ids, ids)
+		})
+	}
+
+	failing := func(context.Context, []string) (map[string]bool, error) {
+		return nil, errors.New("directory unavailable")
+	}
+
+	page := handlers.NewPageBuilder(handlers.WithReadable(context.Background(), failing), scim.ListRequestParams{Count: 1})
+
+	require.NoError(t, page.Add(scim.Resource{ID: "1"}))
+
+	_, err := page.Page()
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package resources
+
+import (
+	"context"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func (h ResourceHandler) Create(ctx context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	id := uuid.NewString()
+
+	logger := h.logger.With().Ctx(ctx).Str("method", "Create").Str("id", id).Logger()
+	logger.Info().Msg("create resource")
+	logger.Trace().Any("attributes", attributes).Msg("creating resource")
+
+	if err := h.validator.Validate(attributes); err != nil {
+		logger.Err(err).Msg("invalid resource")
+		return scim.Resource{}, err
+	}
+
+	resource, err := h.setResource(ctx, &dsc.Object{Type: h.resourceType.SourceObjectType, Id: id}, attributes, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("response", resource).Msg("resource created")
+
+	return resource, nil
+}
+
+// setResource writes the source object of a resource with the given attributes and syncs the transformed
+// directory objects and relations. The writes are rolled back if any of them fails.
+func (h ResourceHandler) setResource(
+	ctx context.Context,
+	object *dsc.Object,
+	attributes scim.ResourceAttributes,
+	logger zerolog.Logger,
+) (scim.Resource, error) {
+	props, err := structpb.NewStruct(attributes)
+	if err != nil {
+		logger.Err(err).Msg("failed to convert attributes to struct")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	object.Properties = props
+
+	object.DisplayName, _ = attributes["displayName"].(string)
+	if object.GetDisplayName() == "" {
+		object.DisplayName = object.GetId()
+	}
+
+	converter := convert.NewConverter(h.cfg)
+
+	var result scim.Resource
+
+	err = h.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		sourceResp, err := h.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
+			Object: object,
+		})
+		if err != nil {
+			logger.Err(err).Msg("failed to set resource")
+			return handlers.VersionConflict(err)
+		}
+
+		_, transformSpan := handlers.StartSpan(ctx, "convert.TransformResourceType")
+		transformResult, err := converter.TransformResourceType(attributes, object.GetId(), h.resourceType)
+
+		transformSpan.End()
+
+		if err != nil {
+			logger.Err(err).Msg("failed to transform resource")
+			return serrors.ScimErrorInvalidSyntax
+		}
+
+		if _, err := h.dirClient.SetResource(ctx, h.resourceType, object.GetId(), transformResult); err != nil {
+			logger.Err(err).Msg("failed to sync resource")
+			return err
+		}
+
+		result = converter.ObjectToResource(sourceResp.GetResult(), convert.ObjectMeta(sourceResp.GetResult()))
+
+		return nil
+	})
+
+	return result, err
+}
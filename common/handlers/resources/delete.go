@@ -0,0 +1,35 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common/handlers"
+)
+
+func (h ResourceHandler) Delete(ctx context.Context, id string) error {
+	logger := h.logger.With().Ctx(ctx).Str("method", "Delete").Str("id", id).Logger()
+	logger.Info().Msg("delete resource")
+
+	if handlers.HasIfMatch(ctx) {
+		object, err := h.getSourceObject(ctx, id)
+		if err != nil {
+			logger.Err(err).Msg("failed to get resource")
+			return err
+		}
+
+		if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+			logger.Err(err).Msg("precondition failed")
+			return err
+		}
+	}
+
+	err := h.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		return h.dirClient.DeleteResource(ctx, h.resourceType, id)
+	})
+	if err != nil {
+		logger.Err(err).Msg("failed to delete resource")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package resources
+
+import (
+	"context"
+	"errors"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+func (h ResourceHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
+	logger := h.logger.With().Ctx(ctx).Str("method", "Get").Str("id", id).Logger()
+	logger.Info().Msg("get resource")
+
+	object, err := h.getSourceObject(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get resource")
+		return scim.Resource{}, err
+	}
+
+	converter := convert.NewConverter(h.cfg)
+
+	return converter.ObjectToResource(object, convert.ObjectMeta(object)), nil
+}
+
+func (h ResourceHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
+	logger := h.logger.With().Ctx(ctx).Str("method", "GetAll").Logger()
+	logger.Info().Msg("getting all resources")
+
+	converter := convert.NewConverter(h.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
+
+	err := h.dirClient.ForEachObject(ctx, h.resourceType.SourceObjectType, func(object *dsc.Object) error {
+		resource := converter.ObjectToResource(object, convert.ObjectMeta(object))
+
+		if params.FilterValidator == nil || params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to read resources")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to read resources")
+		return scim.Page{}, err
+	}
+
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("resources read")
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory"
+	"github.com/aserto-dev/scim/common/handlers"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResourceHandler handles the resources of a resource type declared in the configuration. Resources are stored
+// as source objects holding the resource attributes, and transformed into directory objects and relations by
+// the template section of the resource type.
+type ResourceHandler struct {
+	cfg          *convert.TransformConfig
+	resourceType *config.ResourceType
+	logger       *zerolog.Logger
+	dirClient    *directory.Client
+	validator    *handlers.Validator
+}
+
+func NewResourceHandler(logger *zerolog.Logger,
+	cfg *convert.TransformConfig,
+	resourceType *config.ResourceType,
+	dsClient *ds.Client,
+	validator *handlers.Validator,
+) (*ResourceHandler, error) {
+	resourceLogger := logger.With().Str("component", "resource-handler").Str("resource_type", resourceType.Name).Logger()
+	dirClient := directory.NewDirectoryClient(cfg, &resourceLogger, dsClient)
+
+	return &ResourceHandler{
+		cfg:          cfg,
+		resourceType: resourceType,
+		logger:       &resourceLogger,
+		dirClient:    dirClient,
+		validator:    validator,
+	}, nil
+}
+
+// getSourceObject returns the source object of a resource, or a not found error if it doesn't exist.
+func (h ResourceHandler) getSourceObject(ctx context.Context, id string) (*dsc.Object, error) {
+	resp, err := h.dirClient.DS().Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType: h.resourceType.SourceObjectType,
+		ObjectId:   id,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return nil, serrors.ScimErrorResourceNotFound(id)
+		}
+
+		return nil, err
+	}
+
+	return resp.GetResult(), nil
+}
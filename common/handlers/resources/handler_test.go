@@ -0,0 +1,163 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// deviceTemplate transforms devices into device objects owned by the user named in their owner attribute.
+const deviceTemplate = `{
+  "objects": [
+    {
+      "id": "{{ $.objectId }}",
+      "type": "{{ $.resourceType.object_type }}",
+      "displayName": "{{ $.input.displayName }}"
+    }
+  ],
+  "relations": [
+  {{- if $.input.owner }}
+    {
+      "object_type": "{{ $.resourceType.object_type }}",
+      "object_id": "{{ $.objectId }}",
+      "relation": "owner",
+      "subject_type": "user",
+      "subject_id": "{{ $.input.owner }}"
+    }
+  {{- end }}
+  ]
+}`
+
+func newDeviceHandler(t *testing.T, dir *directorytest.Directory, relations ...string) *ResourceHandler {
+	t.Helper()
+
+	resourceType := &config.ResourceType{
+		Name:             "Device",
+		Endpoint:         "/Devices",
+		SourceObjectType: "scim_device",
+		ObjectType:       "device",
+		Relations:        relations,
+	}
+
+	cfg := (&convert.TransformConfig{Config: &config.Config{
+		User:          &config.User{ObjectType: "user"},
+		ResourceTypes: []*config.ResourceType{resourceType},
+	}}).WithTemplate([]byte(deviceTemplate))
+
+	validator := handlers.NewValidator(schema.Schema{
+		ID:   "urn:example:params:scim:schemas:core:2.0:Device",
+		Name: optional.NewString("Device"),
+		Attributes: []schema.CoreAttribute{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "displayName", Required: true})),
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "owner"})),
+		},
+	})
+
+	logger := zerolog.Nop()
+
+	handler, err := NewResourceHandler(&logger, cfg, resourceType, dir.Client(), validator)
+	require.NoError(t, err)
+
+	return handler
+}
+
+func deviceRelation(deviceID, relation, userID string) *dsc.Relation {
+	return &dsc.Relation{ObjectType: "device", ObjectId: deviceID, Relation: relation, SubjectType: "user", SubjectId: userID}
+}
+
+func TestCreateGetDelete(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newDeviceHandler(t, dir)
+	ctx := context.Background()
+
+	created, err := handler.Create(ctx, scim.ResourceAttributes{"displayName": "laptop", "owner": "rick"})
+	assert.NoError(err)
+	assert.NotEmpty(created.ID)
+	assert.Equal("laptop", created.Attributes["displayName"])
+
+	assert.Contains(dir.Objects, "scim_device:"+created.ID)
+	assert.Equal("laptop", dir.Objects["device:"+created.ID].GetDisplayName())
+	assert.Contains(dir.Relations, directorytest.RelationKey(deviceRelation(created.ID, "owner", "rick")))
+
+	resource, err := handler.Get(ctx, created.ID)
+	assert.NoError(err)
+	assert.Equal(created.ID, resource.ID)
+	assert.Equal("rick", resource.Attributes["owner"])
+
+	assert.NoError(handler.Delete(ctx, created.ID))
+	assert.Empty(dir.Objects)
+	assert.Empty(dir.Relations)
+
+	_, err = handler.Get(ctx, created.ID)
+	assert.Equal(serrors.ScimErrorResourceNotFound(created.ID), err)
+}
+
+func TestReplaceKeepsUnownedRelations(t *testing.T) {
+	tests := []struct {
+		name       string
+		relations  []string
+		attributes scim.ResourceAttributes
+		kept       []*dsc.Relation
+		removed    []*dsc.Relation
+	}{
+		{
+			name:       "relation produced by the template",
+			attributes: scim.ResourceAttributes{"displayName": "laptop", "owner": "morty"},
+			kept:       []*dsc.Relation{deviceRelation("laptop", "owner", "morty"), deviceRelation("laptop", "viewer", "summer")},
+			removed:    []*dsc.Relation{deviceRelation("laptop", "owner", "rick")},
+		},
+		{
+			name:       "relation no longer produced by the template",
+			attributes: scim.ResourceAttributes{"displayName": "laptop"},
+			kept:       []*dsc.Relation{deviceRelation("laptop", "owner", "rick"), deviceRelation("laptop", "viewer", "summer")},
+		},
+		{
+			name:       "relation declared by the resource type",
+			relations:  []string{"owner"},
+			attributes: scim.ResourceAttributes{"displayName": "laptop"},
+			kept:       []*dsc.Relation{deviceRelation("laptop", "viewer", "summer")},
+			removed:    []*dsc.Relation{deviceRelation("laptop", "owner", "rick")},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			dir := directorytest.NewDirectory()
+			handler := newDeviceHandler(t, dir, tc.relations...)
+			ctx := context.Background()
+
+			dir.AddObject(&dsc.Object{Type: "scim_device", Id: "laptop"})
+			dir.AddObject(&dsc.Object{Type: "device", Id: "laptop"})
+			dir.AddRelation(deviceRelation("laptop", "owner", "rick"))
+			dir.AddRelation(deviceRelation("laptop", "viewer", "summer"))
+
+			_, err := handler.Replace(ctx, "laptop", tc.attributes)
+			assert.NoError(err)
+
+			for _, relation := range tc.kept {
+				assert.Contains(dir.Relations, directorytest.RelationKey(relation))
+			}
+
+			for _, relation := range tc.removed {
+				assert.NotContains(dir.Relations, directorytest.RelationKey(relation))
+			}
+
+			assert.Len(dir.Relations, len(tc.kept))
+		})
+	}
+}
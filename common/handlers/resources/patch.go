@@ -0,0 +1,50 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+func (h ResourceHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	logger := h.logger.With().Ctx(ctx).Str("method", "Patch").Str("id", id).Logger()
+	logger.Info().Msg("patch resource")
+	logger.Trace().Any("operations", operations).Msg("patching resource")
+
+	object, err := h.getSourceObject(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get resource")
+		return scim.Resource{}, err
+	}
+
+	if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	converter := convert.NewConverter(h.cfg)
+	current := converter.ObjectToResourceAttributes(object)
+
+	attr, err := common.ApplyPatch(current, operations, h.validator.CaseExact)
+	if err != nil {
+		logger.Err(err).Msg("failed to apply operations")
+		return scim.Resource{}, err
+	}
+
+	if err := h.validator.ValidateUpdate(current, attr); err != nil {
+		logger.Err(err).Msg("patched resource is invalid")
+		return scim.Resource{}, err
+	}
+
+	resource, err := h.setResource(ctx, object, attr, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("response", resource).Msg("resource patched")
+
+	return resource, nil
+}
@@ -0,0 +1,42 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+func (h ResourceHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	logger := h.logger.With().Ctx(ctx).Str("method", "Replace").Str("id", id).Logger()
+	logger.Info().Msg("replace resource")
+	logger.Trace().Any("attributes", attributes).Msg("replacing resource")
+
+	object, err := h.getSourceObject(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get resource")
+		return scim.Resource{}, err
+	}
+
+	if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	converter := convert.NewConverter(h.cfg)
+
+	if err := h.validator.ValidateUpdate(converter.ObjectToResourceAttributes(object), attributes); err != nil {
+		logger.Err(err).Msg("invalid resource")
+		return scim.Resource{}, err
+	}
+
+	resource, err := h.setResource(ctx, object, attributes, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("resource", resource).Msg("resource replaced")
+
+	return resource, nil
+}
@@ -0,0 +1,88 @@
+package roles
+
+import (
+	"context"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
+)
+
+func (r RoleResourceHandler) Create(ctx context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	value, ok := attributes["value"].(string)
+	if !ok || value == "" {
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	logger := r.logger.With().Ctx(ctx).Str("method", "Create").Str("value", value).Logger()
+	logger.Info().Msg("create role")
+	logger.Trace().Any("attributes", attributes).Msg("creating role")
+
+	if err := r.validator.Validate(attributes); err != nil {
+		logger.Err(err).Msg("invalid role")
+		return scim.Resource{}, err
+	}
+
+	switch _, err := r.getRole(ctx, value); {
+	case err == nil:
+		logger.Error().Msg("role already exists")
+		return scim.Resource{}, serrors.ScimErrorUniqueness
+	case !isNotFound(err):
+		logger.Err(err).Msg("failed to get role")
+		return scim.Resource{}, err
+	}
+
+	result, err := r.setRole(ctx, &dsc.Object{Type: r.cfg.Role.ObjectType, Id: value}, attributes, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("response", result).Msg("role created")
+
+	return result, nil
+}
+
+// setRole writes the role object and its role relations. The writes are rolled back if any of them fails.
+func (r RoleResourceHandler) setRole(
+	ctx context.Context,
+	object *dsc.Object,
+	attributes scim.ResourceAttributes,
+	logger zerolog.Logger,
+) (scim.Resource, error) {
+	object.DisplayName, _ = attributes["displayName"].(string)
+	if object.GetDisplayName() == "" {
+		object.DisplayName = object.GetId()
+	}
+
+	var result scim.Resource
+
+	err := r.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		resp, err := r.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
+			Object: object,
+		})
+		if err != nil {
+			logger.Err(err).Msg("failed to set role")
+			return handlers.VersionConflict(err)
+		}
+
+		if err := r.dirClient.SetRoleMembers(ctx, object.GetId(), memberIDs(attributes)); err != nil {
+			logger.Err(err).Msg("failed to set role members")
+			return err
+		}
+
+		members, err := r.dirClient.GetRoleMembers(ctx, object.GetId())
+		if err != nil {
+			logger.Err(err).Msg("failed to read role members")
+			return err
+		}
+
+		result = roleToResource(resp.GetResult(), members[object.GetId()])
+
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,35 @@
+package roles
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common/handlers"
+)
+
+func (r RoleResourceHandler) Delete(ctx context.Context, id string) error {
+	logger := r.logger.With().Ctx(ctx).Str("method", "Delete").Str("id", id).Logger()
+	logger.Info().Msg("delete role")
+
+	if handlers.HasIfMatch(ctx) {
+		object, err := r.getRole(ctx, id)
+		if err != nil {
+			logger.Err(err).Msg("failed to get role")
+			return err
+		}
+
+		if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+			logger.Err(err).Msg("precondition failed")
+			return err
+		}
+	}
+
+	err := r.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		return r.dirClient.DeleteRole(ctx, id)
+	})
+	if err != nil {
+		logger.Err(err).Msg("failed to delete role")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package roles
+
+import (
+	"context"
+	"errors"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+func (r RoleResourceHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
+	logger := r.logger.With().Ctx(ctx).Str("method", "Get").Str("id", id).Logger()
+	logger.Info().Msg("get role")
+
+	object, err := r.getRole(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role")
+		return scim.Resource{}, err
+	}
+
+	members, err := r.dirClient.GetRoleMembers(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role members")
+		return scim.Resource{}, err
+	}
+
+	return roleToResource(object, members[id]), nil
+}
+
+func (r RoleResourceHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
+	logger := r.logger.With().Ctx(ctx).Str("method", "GetAll").Logger()
+	logger.Info().Msg("getting all roles")
+
+	members, err := r.dirClient.GetRoleMembers(ctx, "")
+	if err != nil {
+		logger.Err(err).Msg("failed to read role members")
+		return scim.Page{}, err
+	}
+
+	page := handlers.NewPageBuilder(ctx, params)
+
+	err = r.dirClient.ForEachObject(ctx, r.cfg.Role.ObjectType, func(object *dsc.Object) error {
+		resource := roleToResource(object, members[object.GetId()])
+
+		if params.FilterValidator == nil || params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to read roles")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to read roles")
+		return scim.Page{}, err
+	}
+
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("roles read")
+
+	return result, nil
+}
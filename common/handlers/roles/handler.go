@@ -0,0 +1,99 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RoleResourceHandler handles the role objects of the directory. The id of a role is its value, as used in the
+// "roles" attribute of users, and its members are the users holding the role through the configured role relation.
+type RoleResourceHandler struct {
+	cfg       *convert.TransformConfig
+	logger    *zerolog.Logger
+	dirClient *directory.Client
+	validator *handlers.Validator
+}
+
+func NewRoleResourceHandler(logger *zerolog.Logger,
+	cfg *convert.TransformConfig,
+	dsClient *ds.Client,
+	validator *handlers.Validator,
+) (*RoleResourceHandler, error) {
+	roleLogger := logger.With().Str("component", "roles-handler").Logger()
+	dirClient := directory.NewDirectoryClient(cfg, &roleLogger, dsClient)
+
+	return &RoleResourceHandler{
+		cfg:       cfg,
+		logger:    &roleLogger,
+		dirClient: dirClient,
+		validator: validator,
+	}, nil
+}
+
+// getRole returns the object of a role, or a not found error if it doesn't exist.
+func (r RoleResourceHandler) getRole(ctx context.Context, id string) (*dsc.Object, error) {
+	resp, err := r.dirClient.DS().Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType: r.cfg.Role.ObjectType,
+		ObjectId:   id,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return nil, serrors.ScimErrorResourceNotFound(id)
+		}
+
+		return nil, err
+	}
+
+	return resp.GetResult(), nil
+}
+
+// isNotFound reports whether err is the not found error returned by getRole.
+func isNotFound(err error) bool {
+	var scimErr serrors.ScimError
+	return errors.As(err, &scimErr) && scimErr.Status == http.StatusNotFound
+}
+
+func roleToResource(object *dsc.Object, members []any) scim.Resource {
+	attributes := scim.ResourceAttributes{
+		"value":       object.GetId(),
+		"displayName": object.GetDisplayName(),
+	}
+
+	if len(members) > 0 {
+		attributes["members"] = members
+	}
+
+	return scim.Resource{
+		ID:         object.GetId(),
+		Attributes: attributes,
+		Meta:       convert.ObjectMeta(object),
+	}
+}
+
+// memberIDs returns the user ids of the "members" attribute of a role.
+func memberIDs(attributes scim.ResourceAttributes) []string {
+	members, _ := attributes["members"].([]any)
+	ids := make([]string, 0, len(members))
+
+	for _, member := range members {
+		m, _ := member.(map[string]any)
+		if value, ok := m["value"].(string); ok && value != "" {
+			ids = append(ids, value)
+		}
+	}
+
+	return ids
+}
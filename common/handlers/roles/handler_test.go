@@ -0,0 +1,169 @@
+package roles
+
+import (
+	"context"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// unavailableReader fails to read objects.
+type unavailableReader struct {
+	*directorytest.Directory
+}
+
+func (unavailableReader) GetObject(context.Context, *dsr.GetObjectRequest, ...grpc.CallOption) (*dsr.GetObjectResponse, error) {
+	return nil, status.Error(codes.Unavailable, "directory unavailable")
+}
+
+func newRoleHandler(t *testing.T, dir *directorytest.Directory) *RoleResourceHandler {
+	t.Helper()
+
+	cfg := &convert.TransformConfig{Config: &config.Config{
+		User: &config.User{ObjectType: "user", SourceObjectType: "scim.2.0.user"},
+		Role: &config.Role{ObjectType: "role", RoleRelation: "assignee"},
+	}}
+
+	validator := handlers.NewValidator(schema.Schema{
+		Attributes: schema.Attributes{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "value", Required: true})),
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "displayName"})),
+			schema.ComplexCoreAttribute(schema.ComplexParams{
+				MultiValued:   true,
+				Name:          "members",
+				SubAttributes: []schema.SimpleParams{schema.SimpleStringParams(schema.StringParams{Name: "value"})},
+			}),
+		},
+	})
+
+	logger := zerolog.Nop()
+
+	handler, err := NewRoleResourceHandler(&logger, cfg, dir.Client(), validator)
+	require.NoError(t, err)
+
+	return handler
+}
+
+func addSourceUser(t *testing.T, dir *directorytest.Directory, id string, roles ...any) {
+	t.Helper()
+
+	properties := map[string]any{"userName": id}
+	if len(roles) > 0 {
+		properties["roles"] = roles
+	}
+
+	props, err := structpb.NewStruct(properties)
+	require.NoError(t, err)
+
+	dir.AddObject(&dsc.Object{Type: "scim.2.0.user", Id: id, Properties: props, Etag: "1"})
+}
+
+func userRoles(dir *directorytest.Directory, id string) []any {
+	roles, _ := dir.Objects["scim.2.0.user:"+id].GetProperties().AsMap()["roles"].([]any)
+	return roles
+}
+
+func roleRelation(roleID, userID string) *dsc.Relation {
+	return &dsc.Relation{ObjectType: "role", ObjectId: roleID, Relation: "assignee", SubjectType: "user", SubjectId: userID}
+}
+
+func members(ids ...string) []any {
+	result := make([]any, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, map[string]any{"value": id})
+	}
+
+	return result
+}
+
+func TestCreate(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newRoleHandler(t, dir)
+	ctx := context.Background()
+
+	addSourceUser(t, dir, "rick", map[string]any{"value": "scientist", "type": ""})
+
+	role, err := handler.Create(ctx, scim.ResourceAttributes{"value": "admin", "displayName": "Admin", "members": members("rick")})
+	assert.NoError(err)
+	assert.Equal("admin", role.ID)
+	assert.Len(role.Attributes["members"], 1)
+
+	assert.Contains(dir.Relations, directorytest.RelationKey(roleRelation("admin", "rick")))
+	assert.Equal([]any{
+		map[string]any{"value": "scientist", "type": ""},
+		map[string]any{"value": "admin", "display": "Admin", "type": "", "primary": false},
+	}, userRoles(dir, "rick"))
+
+	_, err = handler.Create(ctx, scim.ResourceAttributes{"value": "admin"})
+	assert.Equal(serrors.ScimErrorUniqueness, err)
+}
+
+func TestCreateReadError(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newRoleHandler(t, dir)
+	handler.dirClient.DS().Reader = unavailableReader{dir}
+
+	_, err := handler.Create(context.Background(), scim.ResourceAttributes{"value": "admin"})
+	assert.Equal(codes.Unavailable, status.Code(err))
+	assert.Empty(dir.Objects)
+}
+
+func TestReplaceMembers(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newRoleHandler(t, dir)
+	ctx := context.Background()
+
+	addSourceUser(t, dir, "rick", map[string]any{"value": "admin", "type": ""})
+	addSourceUser(t, dir, "morty")
+	dir.AddObject(&dsc.Object{Type: "role", Id: "admin", DisplayName: "Admin"})
+	dir.AddRelation(roleRelation("admin", "rick"))
+
+	role, err := handler.Replace(ctx, "admin", scim.ResourceAttributes{"value": "admin", "members": members("morty")})
+	assert.NoError(err)
+	assert.Len(role.Attributes["members"], 1)
+
+	assert.NotContains(dir.Relations, directorytest.RelationKey(roleRelation("admin", "rick")))
+	assert.Contains(dir.Relations, directorytest.RelationKey(roleRelation("admin", "morty")))
+	assert.Empty(userRoles(dir, "rick"))
+	assert.Equal([]any{map[string]any{"value": "admin", "display": "admin", "type": "", "primary": false}}, userRoles(dir, "morty"))
+}
+
+func TestDelete(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newRoleHandler(t, dir)
+	ctx := context.Background()
+
+	addSourceUser(t, dir, "rick", map[string]any{"value": "admin", "type": ""}, map[string]any{"value": "scientist", "type": ""})
+	dir.AddObject(&dsc.Object{Type: "role", Id: "admin"})
+	dir.AddRelation(roleRelation("admin", "rick"))
+
+	assert.NoError(handler.Delete(ctx, "admin"))
+	assert.NotContains(dir.Objects, "role:admin")
+	assert.Empty(dir.Relations)
+	assert.Equal([]any{map[string]any{"value": "scientist", "type": ""}}, userRoles(dir, "rick"))
+
+	_, err := handler.Get(ctx, "admin")
+	assert.Equal(serrors.ScimErrorResourceNotFound("admin"), err)
+}
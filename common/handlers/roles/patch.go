@@ -0,0 +1,54 @@
+package roles
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+func (r RoleResourceHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	logger := r.logger.With().Ctx(ctx).Str("method", "Patch").Str("id", id).Logger()
+	logger.Info().Msg("patch role")
+	logger.Trace().Any("operations", operations).Msg("patching role")
+
+	object, err := r.getRole(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role")
+		return scim.Resource{}, err
+	}
+
+	if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	members, err := r.dirClient.GetRoleMembers(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role members")
+		return scim.Resource{}, err
+	}
+
+	current := roleToResource(object, members[id]).Attributes
+
+	attr, err := common.ApplyPatch(current, operations, r.validator.CaseExact)
+	if err != nil {
+		logger.Err(err).Msg("failed to apply operations")
+		return scim.Resource{}, err
+	}
+
+	if err := r.validator.ValidateUpdate(current, attr); err != nil {
+		logger.Err(err).Msg("patched role is invalid")
+		return scim.Resource{}, err
+	}
+
+	resource, err := r.setRole(ctx, object, attr, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("response", resource).Msg("role patched")
+
+	return resource, nil
+}
@@ -0,0 +1,47 @@
+package roles
+
+import (
+	"context"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+)
+
+// Replace updates the display name and the members of a role. Other properties of the role object, such as
+// those set by the transform of users, are kept.
+func (r RoleResourceHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	logger := r.logger.With().Ctx(ctx).Str("method", "Replace").Str("id", id).Logger()
+	logger.Info().Msg("replace role")
+	logger.Trace().Any("attributes", attributes).Msg("replacing role")
+
+	object, err := r.getRole(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role")
+		return scim.Resource{}, err
+	}
+
+	if err := handlers.CheckIfMatch(ctx, object.GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	members, err := r.dirClient.GetRoleMembers(ctx, id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get role members")
+		return scim.Resource{}, err
+	}
+
+	if err := r.validator.ValidateUpdate(roleToResource(object, members[id]).Attributes, attributes); err != nil {
+		logger.Err(err).Msg("invalid role")
+		return scim.Resource{}, err
+	}
+
+	resource, err := r.setRole(ctx, object, attributes, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	logger.Trace().Any("resource", resource).Msg("role replaced")
+
+	return resource, nil
+}
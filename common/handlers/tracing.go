@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aserto-dev/scim/common/handlers")
+
+// StartSpan starts a span for a stage of a handler, such as converting or transforming a resource.
+// The caller must end the returned span.
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name)
+}
@@ -5,6 +5,7 @@ import (
 
 	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/aserto-dev/scim/common/model"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
@@ -17,10 +18,15 @@ func (u UsersResourceHandler) Create(ctx context.Context, attributes scim.Resour
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	logger := u.logger.With().Str("method", "Create").Str("userName", userName).Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "Create").Str("userName", userName).Logger()
 	logger.Info().Msg("create user")
 	logger.Trace().Any("attributes", attributes).Msg("creating user")
 
+	if err := u.validator.Validate(attributes); err != nil {
+		logger.Err(err).Msg("invalid user")
+		return scim.Resource{}, err
+	}
+
 	user, err := u.convertAttributesToUser(attributes, logger)
 	if err != nil {
 		return scim.Resource{}, err
@@ -28,7 +34,12 @@ func (u UsersResourceHandler) Create(ctx context.Context, attributes scim.Resour
 
 	converter := convert.NewConverter(u.cfg)
 
-	result, err := u.createUserObject(ctx, user, attributes, converter, logger)
+	var result scim.Resource
+
+	err = u.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		result, err = u.createUserObject(ctx, user, attributes, converter, logger)
+		return err
+	})
 	if err != nil {
 		return scim.Resource{}, err
 	}
@@ -55,13 +66,22 @@ func (u UsersResourceHandler) createUserObject(
 	converter *convert.Converter,
 	logger zerolog.Logger,
 ) (scim.Resource, error) {
+	_, convertSpan := handlers.StartSpan(ctx, "convert.SCIMUserToObject")
 	object, err := converter.SCIMUserToObject(user)
+
+	convertSpan.End()
+
 	if err != nil {
 		logger.Err(err).Msg("failed to convert user to object")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	sourceUserResp, err := u.dirClient.DS().Writer.SetObject(ctx, &dsw.SetObjectRequest{
+	if err := converter.SetExtensionProperties(object, attributes, u.cfg.User.SchemaExtensions); err != nil {
+		logger.Err(err).Msg("failed to convert user extensions")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	sourceUserResp, err := u.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
 		Object: object,
 	})
 	if err != nil {
@@ -85,17 +105,20 @@ func (u UsersResourceHandler) processUserResponse(
 		return scim.Resource{}, err
 	}
 
+	_, transformSpan := handlers.StartSpan(ctx, "convert.TransformResource")
 	transformResult, err := converter.TransformResource(userMap, sourceUserResp.GetResult().GetId(), "user")
+
+	transformSpan.End()
+
 	if err != nil {
 		logger.Err(err).Msg("failed to convert user to object")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
 	}
 
-	meta, err := u.dirClient.SetUser(ctx, sourceUserResp.GetResult().GetId(), transformResult, attributes)
-	if err != nil {
+	if _, err := u.dirClient.SetUser(ctx, sourceUserResp.GetResult().GetId(), transformResult, attributes); err != nil {
 		logger.Err(err).Msg("failed to sync user")
 		return scim.Resource{}, err
 	}
 
-	return converter.ObjectToResource(sourceUserResp.GetResult(), meta), nil
+	return objectToResource(converter, sourceUserResp.GetResult()), nil
 }
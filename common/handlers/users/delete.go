@@ -13,16 +13,24 @@ import (
 )
 
 func (u UsersResourceHandler) Delete(ctx context.Context, id string) error {
-	logger := u.logger.With().Str("method", "Delete").Str("id", id).Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "Delete").Str("id", id).Logger()
 	logger.Info().Msg("delete user")
 
-	if err := u.deleteUserIdentities(ctx, id, logger); err != nil {
+	if err := u.checkIfMatch(ctx, id); err != nil {
+		logger.Err(err).Msg("precondition failed")
 		return err
 	}
 
-	logger.Trace().Msg("deleting user")
+	err := u.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		if err := u.deleteUserIdentities(ctx, id, logger); err != nil {
+			return err
+		}
+
+		logger.Trace().Msg("deleting user")
 
-	if err := u.deleteUserObjects(ctx, id, logger); err != nil {
+		return u.deleteUserObjects(ctx, id, logger)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -107,7 +115,7 @@ func (u UsersResourceHandler) getIdentityObjectID(relation *dsc.Relation, logger
 }
 
 func (u UsersResourceHandler) deleteIdentityObject(ctx context.Context, objectID string, logger zerolog.Logger) error {
-	_, err := u.dirClient.DS().Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err := u.dirClient.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectId:      objectID,
 		ObjectType:    u.cfg.User.IdentityObjectType,
 		WithRelations: true,
@@ -121,7 +129,7 @@ func (u UsersResourceHandler) deleteIdentityObject(ctx context.Context, objectID
 }
 
 func (u UsersResourceHandler) deleteUserObjects(ctx context.Context, id string, logger zerolog.Logger) error {
-	_, err := u.dirClient.DS().Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err := u.dirClient.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectType:    u.cfg.User.ObjectType,
 		ObjectId:      id,
 		WithRelations: true,
@@ -139,7 +147,7 @@ func (u UsersResourceHandler) deleteUserObjects(ctx context.Context, id string,
 
 	logger.Trace().Msg("deleting user source object")
 
-	_, err = u.dirClient.DS().Writer.DeleteObject(ctx, &dsw.DeleteObjectRequest{
+	_, err = u.dirClient.DeleteObject(ctx, &dsw.DeleteObjectRequest{
 		ObjectType:    u.cfg.User.SourceObjectType,
 		ObjectId:      id,
 		WithRelations: true,
@@ -0,0 +1,215 @@
+package users
+
+import (
+	"context"
+	"strings"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/scim2/filter-parser/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type lookupKind int
+
+const (
+	// lookupByID resolves the user by its source object id.
+	lookupByID lookupKind = iota
+	// lookupByIdentity resolves the user through the identity object with the given id.
+	lookupByIdentity
+)
+
+// lookup is a single directory access that yields candidate users for a filter. The default template stores
+// identities with the attribute values as sent by the client, so an identity lookup misses users whose value
+// differs in case, and any user whose identities are named differently by a custom template.
+type lookup struct {
+	kind  lookupKind
+	value string
+}
+
+// identityAttributes are the user attributes that are stored as identity objects, keyed by their lower-cased
+// attribute path.
+var identityAttributes = map[string]bool{
+	"username":     true,
+	"externalid":   true,
+	"emails.value": true,
+}
+
+// planFilter returns the directory lookups that produce a superset of the users matching the expression.
+// The boolean result is false when the expression can't be resolved without scanning all users.
+func planFilter(expr filter.Expression) ([]lookup, bool) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		return planAttributeExpression(e, "")
+	case *filter.ValuePath:
+		if !isCoreUserPath(e.AttributePath) || e.AttributePath.SubAttribute != nil {
+			return nil, false
+		}
+
+		attrExpr, ok := e.ValueFilter.(*filter.AttributeExpression)
+		if !ok || attrExpr.AttributePath.SubAttribute != nil || attrExpr.AttributePath.URIPrefix != nil {
+			return nil, false
+		}
+
+		return planAttributeExpression(attrExpr, e.AttributePath.AttributeName)
+	case *filter.LogicalExpression:
+		left, leftOK := planFilter(e.Left)
+		right, rightOK := planFilter(e.Right)
+
+		switch e.Operator {
+		case filter.AND:
+			// Either side narrows the result; the full expression is applied to the candidates afterwards.
+			if leftOK {
+				return left, true
+			}
+
+			if rightOK {
+				return right, true
+			}
+		case filter.OR:
+			if leftOK && rightOK {
+				return append(left, right...), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func planAttributeExpression(e *filter.AttributeExpression, parent string) ([]lookup, bool) {
+	if e.Operator != filter.EQ || !isCoreUserPath(e.AttributePath) {
+		return nil, false
+	}
+
+	value, ok := e.CompareValue.(string)
+	if !ok || value == "" {
+		return nil, false
+	}
+
+	path := e.AttributePath.AttributeName
+	if e.AttributePath.SubAttribute != nil {
+		path += "." + *e.AttributePath.SubAttribute
+	}
+
+	if parent != "" {
+		path = parent + "." + path
+	}
+
+	path = strings.ToLower(path)
+
+	if path == "id" {
+		return []lookup{{kind: lookupByID, value: value}}, true
+	}
+
+	if !identityAttributes[path] {
+		return nil, false
+	}
+
+	return []lookup{{kind: lookupByIdentity, value: value}}, true
+}
+
+func isCoreUserPath(attrPath filter.AttributePath) bool {
+	return attrPath.URIPrefix == nil || strings.EqualFold(*attrPath.URIPrefix, schema.CoreUserSchema().ID)
+}
+
+// resolveLookups reads the source user objects addressed by the given lookups, skipping duplicates and missing users.
+// The boolean result is false when an identity lookup finds no identity, in which case the users must be scanned
+// to find those whose identity isn't named after the value.
+func (u UsersResourceHandler) resolveLookups(ctx context.Context, lookups []lookup, logger zerolog.Logger) ([]*dsc.Object, bool, error) {
+	seen := make(map[string]bool)
+	objects := make([]*dsc.Object, 0, len(lookups))
+
+	for _, l := range lookups {
+		userIDs := []string{l.value}
+
+		if l.kind == lookupByIdentity {
+			var err error
+
+			userIDs, err = u.identityUserIDs(ctx, l.value)
+			if err != nil {
+				logger.Err(err).Str("identity", l.value).Msg("failed to resolve identity")
+				return nil, false, err
+			}
+
+			if len(userIDs) == 0 {
+				return nil, false, nil
+			}
+		}
+
+		for _, userID := range userIDs {
+			if seen[userID] {
+				continue
+			}
+
+			seen[userID] = true
+
+			object, err := u.getSourceUser(ctx, userID)
+			if err != nil {
+				logger.Err(err).Str("user_id", userID).Msg("failed to get user")
+				return nil, false, err
+			}
+
+			if object != nil {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return objects, true, nil
+}
+
+// identityUserIDs returns the ids of the users linked to the identity through the configured identity relation.
+func (u UsersResourceHandler) identityUserIDs(ctx context.Context, identity string) ([]string, error) {
+	identityRelation, err := u.cfg.ParseIdentityRelation("", identity)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.dirClient.DS().Reader.GetRelations(ctx, &dsr.GetRelationsRequest{
+		ObjectType:  identityRelation.GetObjectType(),
+		ObjectId:    identityRelation.GetObjectId(),
+		Relation:    identityRelation.GetRelation(),
+		SubjectType: identityRelation.GetSubjectType(),
+		SubjectId:   identityRelation.GetSubjectId(),
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(resp.GetResults()))
+
+	for _, rel := range resp.GetResults() {
+		if rel.GetObjectType() == u.cfg.User.ObjectType {
+			userIDs = append(userIDs, rel.GetObjectId())
+		} else {
+			userIDs = append(userIDs, rel.GetSubjectId())
+		}
+	}
+
+	return userIDs, nil
+}
+
+// getSourceUser returns the source user object with the given id or nil if it doesn't exist.
+func (u UsersResourceHandler) getSourceUser(ctx context.Context, id string) (*dsc.Object, error) {
+	resp, err := u.dirClient.DS().Reader.GetObject(ctx, &dsr.GetObjectRequest{
+		ObjectType:    u.cfg.User.SourceObjectType,
+		ObjectId:      id,
+		WithRelations: false,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return resp.GetResult(), nil
+}
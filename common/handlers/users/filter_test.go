@@ -0,0 +1,174 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/rs/zerolog"
+	"github.com/scim2/filter-parser/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		lookups []lookup
+		ok      bool
+	}{
+		{
+			name:    "userName eq",
+			filter:  `userName eq "rick@the-citadel.com"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick@the-citadel.com"}},
+			ok:      true,
+		},
+		{
+			name:    "mixed-case value is looked up as sent, not case-exact",
+			filter:  `userName eq "Rick@The-Citadel.com"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "Rick@The-Citadel.com"}},
+			ok:      true,
+		},
+		{
+			name:    "attribute names are case insensitive",
+			filter:  `USERNAME eq "rick"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick"}},
+			ok:      true,
+		},
+		{
+			name:    "externalId eq",
+			filter:  `externalId eq "00u1"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "00u1"}},
+			ok:      true,
+		},
+		{
+			name:    "emails.value eq",
+			filter:  `emails.value eq "rick@the-citadel.com"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick@the-citadel.com"}},
+			ok:      true,
+		},
+		{
+			name:    "emails value path",
+			filter:  `emails[value eq "rick@the-citadel.com"]`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick@the-citadel.com"}},
+			ok:      true,
+		},
+		{
+			name:    "id eq",
+			filter:  `id eq "cmljaw=="`,
+			lookups: []lookup{{kind: lookupByID, value: "cmljaw=="}},
+			ok:      true,
+		},
+		{
+			name:    "urn qualified",
+			filter:  `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "rick"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick"}},
+			ok:      true,
+		},
+		{
+			name:    "and with one indexable side",
+			filter:  `active eq true and userName eq "rick"`,
+			lookups: []lookup{{kind: lookupByIdentity, value: "rick"}},
+			ok:      true,
+		},
+		{
+			name:   "or with one unindexable side",
+			filter: `userName eq "rick" or displayName eq "Morty"`,
+		},
+		{
+			name:   "or with two indexable sides",
+			filter: `userName eq "rick" or id eq "bW9ydHk="`,
+			lookups: []lookup{
+				{kind: lookupByIdentity, value: "rick"},
+				{kind: lookupByID, value: "bW9ydHk="},
+			},
+			ok: true,
+		},
+		{
+			name:   "unsupported operator",
+			filter: `userName sw "ri"`,
+		},
+		{
+			name:   "unindexed attribute",
+			filter: `displayName eq "Rick Sanchez"`,
+		},
+		{
+			name:   "emails type",
+			filter: `emails[type eq "work"]`,
+		},
+		{
+			name:   "negation",
+			filter: `not (userName eq "rick")`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			expr, err := filter.ParseFilter([]byte(tc.filter))
+			assert.NoError(err)
+
+			lookups, ok := planFilter(expr)
+			assert.Equal(tc.ok, ok)
+			assert.Equal(tc.lookups, lookups)
+		})
+	}
+}
+
+func TestResolveLookups(t *testing.T) {
+	cfg, err := convert.NewTransformConfig(&config.Config{User: &config.User{
+		ObjectType:         "user",
+		IdentityObjectType: "identity",
+		IdentityRelation:   "user#identifier",
+		SourceObjectType:   "scim.2.0.user",
+	}})
+	require.NoError(t, err)
+
+	// A custom template names identities differently from the attribute values they were created from.
+	reader := &fakeReader{
+		users:      []*dsc.Object{{Type: "scim.2.0.user", Id: "rick"}},
+		identities: map[string]string{"rick": "rick", "okta|00u1": "rick"},
+	}
+
+	logger := zerolog.Nop()
+	handler, err := NewUsersResourceHandler(&logger, cfg, &ds.Client{Reader: reader}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		lookups  []lookup
+		users    int
+		resolved bool
+	}{
+		{
+			name:     "identity found",
+			lookups:  []lookup{{kind: lookupByIdentity, value: "rick"}},
+			users:    1,
+			resolved: true,
+		},
+		{
+			name:    "identity missed",
+			lookups: []lookup{{kind: lookupByIdentity, value: "00u1"}},
+		},
+		{
+			name:     "id of missing user",
+			lookups:  []lookup{{kind: lookupByID, value: "morty"}},
+			resolved: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			objects, resolved, err := handler.resolveLookups(context.Background(), tc.lookups, logger)
+			assert.NoError(err)
+			assert.Equal(tc.resolved, resolved)
+			assert.Len(objects, tc.users)
+		})
+	}
+}
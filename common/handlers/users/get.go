@@ -2,18 +2,21 @@ package users
 
 import (
 	"context"
+	"errors"
 
 	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
 	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 func (u UsersResourceHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
-	logger := u.logger.With().Str("method", "Get").Str("id", id).Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "Get").Str("id", id).Logger()
 	logger.Info().Msg("get user")
 
 	converter := convert.NewConverter(u.cfg)
@@ -34,13 +37,14 @@ func (u UsersResourceHandler) Get(ctx context.Context, id string) (scim.Resource
 		return scim.Resource{}, err
 	}
 
-	createdAt := resp.GetResult().GetCreatedAt().AsTime()
-	updatedAt := resp.GetResult().GetUpdatedAt().AsTime()
-	resource := converter.ObjectToResource(resp.GetResult(), scim.Meta{
-		Created:      &createdAt,
-		LastModified: &updatedAt,
-		Version:      resp.GetResult().GetEtag(),
-	})
+	memberships, err := u.dirClient.GetMemberships(ctx, "", id)
+	if err != nil {
+		logger.Err(err).Msg("failed to get user groups")
+		return scim.Resource{}, err
+	}
+
+	resource := objectToResource(converter, resp.GetResult())
+	memberships.SetGroups(id, resource.Attributes)
 
 	logger.Trace().Any("user", resource).Msg("user retrieved")
 
@@ -48,73 +52,153 @@ func (u UsersResourceHandler) Get(ctx context.Context, id string) (scim.Resource
 }
 
 func (u UsersResourceHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
-	logger := u.logger.With().Str("method", "GetAll").Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "GetAll").Logger()
 	logger.Info().Msg("getting all users")
 
-	var (
-		resources = make([]scim.Resource, 0)
-		pageToken = ""
-		pageSize  = 100
-		skipIndex = 1 // start index is 1-based
-	)
+	if params.FilterValidator != nil {
+		if lookups, ok := planFilter(params.FilterValidator.GetFilter()); ok {
+			logger.Trace().Any("lookups", lookups).Msg("resolving filter through directory lookups")
+
+			page, resolved, err := u.getAllIndexed(ctx, lookups, params, logger)
+			if err != nil || resolved {
+				return page, err
+			}
+
+			logger.Debug().Msg("identity lookup missed, scanning users")
+		}
+	}
 
-	if params.Count != 0 && params.Count < pageSize {
-		pageSize = params.Count
+	if params.FilterValidator == nil || !handlers.ReferencesAttribute(params.FilterValidator.GetFilter(), "groups") {
+		return u.getPage(ctx, params, logger)
 	}
 
+	// The filter needs the groups of every user, so all the group member relations are read.
+	memberships, err := u.dirClient.GetMemberships(ctx, "", "")
+	if err != nil {
+		logger.Err(err).Msg("failed to read user groups")
+		return scim.Page{}, err
+	}
+
+	converter := convert.NewConverter(u.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
+
+	err = u.dirClient.ForEachObject(ctx, u.cfg.User.SourceObjectType, func(object *dsc.Object) error {
+		resource := objectToResource(converter, object)
+		memberships.SetGroups(object.GetId(), resource.Attributes)
+
+		if params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to get users")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to get users")
+		return scim.Page{}, err
+	}
+
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("users read")
+
+	return result, nil
+}
+
+// getPage lists the users matching a filter that doesn't reference their groups, if any, and reads the groups of
+// the users in the page only, rather than all the group member relations.
+func (u UsersResourceHandler) getPage(ctx context.Context, params scim.ListRequestParams, logger zerolog.Logger) (scim.Page, error) {
 	converter := convert.NewConverter(u.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
 
-	for {
-		resp, err := u.getUsers(ctx, pageSize, pageToken)
+	err := u.dirClient.ForEachObject(ctx, u.cfg.User.SourceObjectType, func(object *dsc.Object) error {
+		resource := objectToResource(converter, object)
+
+		if params.FilterValidator == nil || params.FilterValidator.PassesFilter(resource.Attributes) == nil {
+			return page.Add(resource)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, handlers.ErrPageComplete) {
+		logger.Err(err).Msg("failed to get users")
+		return scim.Page{}, err
+	}
+
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to get users")
+		return scim.Page{}, err
+	}
+
+	for _, resource := range result.Resources {
+		memberships, err := u.dirClient.GetMemberships(ctx, "", resource.ID)
 		if err != nil {
-			logger.Err(err).Msg("failed to get users")
+			logger.Err(err).Str("user_id", resource.ID).Msg("failed to read user groups")
 			return scim.Page{}, err
 		}
 
-		pageToken = resp.GetPage().GetNextToken()
+		memberships.SetGroups(resource.ID, resource.Attributes)
+	}
 
-		for _, v := range resp.GetResults() {
-			createdAt := v.GetCreatedAt().AsTime()
-			updatedAt := v.GetUpdatedAt().AsTime()
-			resource := converter.ObjectToResource(v, scim.Meta{
-				Created:      &createdAt,
-				LastModified: &updatedAt,
-				Version:      v.GetEtag(),
-			})
+	logger.Trace().Int("total_results", result.TotalResults).Int("resources", len(result.Resources)).Msg("users read")
 
-			if params.FilterValidator == nil || params.FilterValidator.PassesFilter(resource.Attributes) == nil {
-				if skipIndex < params.StartIndex {
-					skipIndex++
-					continue
-				}
+	return result, nil
+}
 
-				resources = append(resources, resource)
-			}
+// getAllIndexed lists the users found by the directory lookups of a filter. The boolean result is false if the
+// lookups can't be relied on and the users must be scanned instead.
+func (u UsersResourceHandler) getAllIndexed(
+	ctx context.Context,
+	lookups []lookup,
+	params scim.ListRequestParams,
+	logger zerolog.Logger,
+) (scim.Page, bool, error) {
+	objects, resolved, err := u.resolveLookups(ctx, lookups, logger)
+	if err != nil || !resolved {
+		return scim.Page{}, false, err
+	}
 
-			if len(resources) == params.Count {
-				break
-			}
+	converter := convert.NewConverter(u.cfg)
+	page := handlers.NewPageBuilder(ctx, params)
+
+	for _, v := range objects {
+		// Only the groups of the resolved users are read, rather than all the group member relations.
+		memberships, err := u.dirClient.GetMemberships(ctx, "", v.GetId())
+		if err != nil {
+			logger.Err(err).Str("user_id", v.GetId()).Msg("failed to read user groups")
+			return scim.Page{}, false, err
 		}
 
-		if len(resources) >= pageSize || pageToken == "" {
+		resource := objectToResource(converter, v)
+		memberships.SetGroups(v.GetId(), resource.Attributes)
+
+		if params.FilterValidator.PassesFilter(resource.Attributes) != nil {
+			continue
+		}
+
+		if err := page.Add(resource); errors.Is(err, handlers.ErrPageComplete) {
 			break
+		} else if err != nil {
+			logger.Err(err).Msg("failed to get users")
+			return scim.Page{}, false, err
 		}
 	}
 
-	logger.Trace().Int("total_results", len(resources)).Msg("users read")
+	result, err := page.Page()
+	if err != nil {
+		logger.Err(err).Msg("failed to get users")
+		return scim.Page{}, false, err
+	}
+
+	logger.Trace().Int("candidates", len(objects)).Int("total_results", result.TotalResults).Msg("users read")
 
-	return scim.Page{
-		TotalResults: len(resources),
-		Resources:    resources,
-	}, nil
+	return result, true, nil
 }
 
-func (u UsersResourceHandler) getUsers(ctx context.Context, count int, pageToken string) (*dsr.GetObjectsResponse, error) {
-	return u.dirClient.DS().Reader.GetObjects(ctx, &dsr.GetObjectsRequest{
-		ObjectType: u.cfg.User.SourceObjectType,
-		Page: &dsc.PaginationRequest{
-			Size:  int32(count), //nolint:gosec
-			Token: pageToken,
-		},
-	})
+func objectToResource(converter *convert.Converter, object *dsc.Object) scim.Resource {
+	return converter.ObjectToResource(object, convert.ObjectMeta(object))
 }
@@ -0,0 +1,149 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeReader holds source users and the identities they were created with, as stored by the transform.
+type fakeReader struct {
+	dsr.ReaderClient
+
+	users      []*dsc.Object
+	identities map[string]string
+	scanned    bool
+}
+
+func (r *fakeReader) GetRelations(_ context.Context, in *dsr.GetRelationsRequest, _ ...grpc.CallOption) (*dsr.GetRelationsResponse, error) {
+	resp := &dsr.GetRelationsResponse{}
+
+	if userID, ok := r.identities[in.GetSubjectId()]; ok {
+		resp.Results = append(resp.Results, &dsc.Relation{ObjectType: "user", ObjectId: userID, SubjectId: in.GetSubjectId()})
+	}
+
+	return resp, nil
+}
+
+func (r *fakeReader) GetObject(_ context.Context, in *dsr.GetObjectRequest, _ ...grpc.CallOption) (*dsr.GetObjectResponse, error) {
+	for _, user := range r.users {
+		if user.GetId() == in.GetObjectId() {
+			return &dsr.GetObjectResponse{Result: user}, nil
+		}
+	}
+
+	return &dsr.GetObjectResponse{}, nil
+}
+
+func (r *fakeReader) GetObjects(_ context.Context, _ *dsr.GetObjectsRequest, _ ...grpc.CallOption) (*dsr.GetObjectsResponse, error) {
+	r.scanned = true
+	return &dsr.GetObjectsResponse{Results: r.users}, nil
+}
+
+// relationsRecorder records the relation reads of a directory.
+type relationsRecorder struct {
+	*directorytest.Directory
+
+	requests []*dsr.GetRelationsRequest
+}
+
+func (r *relationsRecorder) GetRelations(
+	ctx context.Context,
+	in *dsr.GetRelationsRequest,
+	opts ...grpc.CallOption,
+) (*dsr.GetRelationsResponse, error) {
+	r.requests = append(r.requests, in)
+	return r.Directory.GetRelations(ctx, in, opts...)
+}
+
+func TestGetAllReadsGroupsOfPage(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newUsersHandler(t, dir)
+	recorder := &relationsRecorder{Directory: dir}
+	handler.dirClient.DS().Reader = recorder
+
+	for _, id := range []string{"morty", "rick", "summer"} {
+		properties, err := structpb.NewStruct(map[string]any{"userName": id})
+		assert.NoError(err)
+
+		dir.AddObject(&dsc.Object{Type: "scim.2.0.user", Id: id, Properties: properties})
+	}
+
+	for _, id := range []string{"morty", "rick"} {
+		dir.AddRelation(&dsc.Relation{ObjectType: "group", ObjectId: "council", Relation: "member", SubjectType: "user", SubjectId: id})
+	}
+
+	page, err := handler.GetAll(context.Background(), scim.ListRequestParams{StartIndex: 2, Count: 1})
+	assert.NoError(err)
+	assert.Equal(3, page.TotalResults)
+	assert.Len(page.Resources, 1)
+	assert.Equal("rick", page.Resources[0].ID)
+	assert.Len(page.Resources[0].Attributes["groups"], 1)
+
+	assert.Len(recorder.requests, 1)
+	assert.Equal("rick", recorder.requests[0].GetSubjectId())
+
+	validator, err := filter.NewValidator(`groups[value eq "council"]`, schema.CoreUserSchema())
+	assert.NoError(err)
+
+	page, err = handler.GetAll(context.Background(), scim.ListRequestParams{StartIndex: 1, Count: 10, FilterValidator: &validator})
+	assert.NoError(err)
+	assert.Equal(2, page.TotalResults)
+}
+
+func TestGetAllCaseInsensitiveUserName(t *testing.T) {
+	assert := require.New(t)
+
+	cfg, err := convert.NewTransformConfig(&config.Config{User: &config.User{
+		ObjectType:         "user",
+		IdentityObjectType: "identity",
+		IdentityRelation:   "user#identifier",
+		SourceObjectType:   "scim.2.0.user",
+	}})
+	assert.NoError(err)
+
+	properties, err := structpb.NewStruct(map[string]any{"userName": "rick@the-citadel.com"})
+	assert.NoError(err)
+
+	reader := &fakeReader{
+		users:      []*dsc.Object{{Type: "scim.2.0.user", Id: "rick", Properties: properties}},
+		identities: map[string]string{"rick@the-citadel.com": "rick"},
+	}
+
+	logger := zerolog.Nop()
+	handler, err := NewUsersResourceHandler(&logger, cfg, &ds.Client{Reader: reader}, nil)
+	assert.NoError(err)
+
+	list := func(expr string) scim.Page {
+		validator, err := filter.NewValidator(expr, schema.CoreUserSchema())
+		assert.NoError(err)
+
+		page, err := handler.GetAll(context.Background(), scim.ListRequestParams{StartIndex: 1, Count: 10, FilterValidator: &validator})
+		assert.NoError(err)
+
+		return page
+	}
+
+	// The identity is found without scanning the users.
+	assert.Equal(1, list(`userName eq "rick@the-citadel.com"`).TotalResults)
+	assert.False(reader.scanned)
+
+	// userName isn't case-exact: a value that differs in case misses the identity and is found by a scan.
+	assert.Equal(1, list(`userName eq "Rick@The-Citadel.com"`).TotalResults)
+	assert.True(reader.scanned)
+}
@@ -1,9 +1,13 @@
 package users
 
 import (
+	"context"
+
 	"github.com/aserto-dev/go-aserto/ds/v3"
 	"github.com/aserto-dev/scim/common/convert"
 	"github.com/aserto-dev/scim/common/directory"
+	"github.com/aserto-dev/scim/common/handlers"
+	serrors "github.com/elimity-com/scim/errors"
 
 	"github.com/rs/zerolog"
 )
@@ -12,11 +16,13 @@ type UsersResourceHandler struct {
 	cfg       *convert.TransformConfig
 	logger    *zerolog.Logger
 	dirClient *directory.Client
+	validator *handlers.Validator
 }
 
 func NewUsersResourceHandler(logger *zerolog.Logger,
 	cfg *convert.TransformConfig,
 	dsClient *ds.Client,
+	validator *handlers.Validator,
 ) (*UsersResourceHandler, error) {
 	usersLogger := logger.With().Str("component", "users-handler").Logger()
 
@@ -26,5 +32,24 @@ func NewUsersResourceHandler(logger *zerolog.Logger,
 		cfg:       cfg,
 		logger:    &usersLogger,
 		dirClient: dirClient,
+		validator: validator,
 	}, nil
 }
+
+// checkIfMatch verifies the If-Match precondition of the request, if any, against the current version of the user.
+func (u UsersResourceHandler) checkIfMatch(ctx context.Context, id string) error {
+	if !handlers.HasIfMatch(ctx) {
+		return nil
+	}
+
+	object, err := u.getSourceUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if object == nil {
+		return serrors.ScimErrorResourceNotFound(id)
+	}
+
+	return handlers.CheckIfMatch(ctx, object.GetEtag())
+}
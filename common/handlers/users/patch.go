@@ -8,6 +8,7 @@ import (
 	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
 	"github.com/aserto-dev/scim/common"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
 	"github.com/rs/zerolog"
@@ -17,7 +18,7 @@ import (
 )
 
 func (u UsersResourceHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
-	logger := u.logger.With().Str("method", "Patch").Str("id", id).Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "Patch").Str("id", id).Logger()
 	logger.Info().Msg("patch user")
 	logger.Trace().Any("operations", operations).Msg("patching user")
 
@@ -39,15 +40,30 @@ func (u UsersResourceHandler) Patch(ctx context.Context, id string, operations [
 		return scim.Resource{}, err
 	}
 
-	attr := converter.ObjectToResourceAttributes(getObjResp.GetResult())
+	if err := handlers.CheckIfMatch(ctx, getObjResp.GetResult().GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	current := converter.ObjectToResourceAttributes(getObjResp.GetResult())
 
-	attr, err = u.doOperations(operations, attr)
+	attr, err := common.ApplyPatch(current, operations, u.validator.CaseExact)
 	if err != nil {
 		logger.Err(err).Msg("failed to apply operations")
 		return scim.Resource{}, err
 	}
 
-	resource, err := u.updateUser(ctx, attr, getObjResp.GetResult(), converter, logger)
+	if err := u.validator.ValidateUpdate(current, attr); err != nil {
+		logger.Err(err).Msg("patched user is invalid")
+		return scim.Resource{}, err
+	}
+
+	var resource scim.Resource
+
+	err = u.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		resource, err = u.updateUser(ctx, attr, getObjResp.GetResult(), converter, logger)
+		return err
+	})
 	if err != nil {
 		return scim.Resource{}, err
 	}
@@ -57,24 +73,6 @@ func (u UsersResourceHandler) Patch(ctx context.Context, id string, operations [
 	return resource, nil
 }
 
-func (u UsersResourceHandler) doOperations(
-	operations []scim.PatchOperation,
-	attr scim.ResourceAttributes,
-) (scim.ResourceAttributes, error) {
-	for _, op := range operations {
-		switch op.Op {
-		case scim.PatchOperationAdd:
-			return common.HandlePatchOPAdd(attr, op)
-		case scim.PatchOperationRemove:
-			return common.HandlePatchOPRemove(attr, op)
-		case scim.PatchOperationReplace:
-			return common.HandlePatchOPReplace(attr, op)
-		}
-	}
-
-	return attr, nil
-}
-
 func (u UsersResourceHandler) updateUser(
 	ctx context.Context,
 	attr map[string]interface{},
@@ -82,7 +80,11 @@ func (u UsersResourceHandler) updateUser(
 	converter *convert.Converter,
 	logger zerolog.Logger,
 ) (scim.Resource, error) {
+	_, transformSpan := handlers.StartSpan(ctx, "convert.TransformResource")
 	transformResult, err := converter.TransformResource(attr, userObj.GetId(), "user")
+
+	transformSpan.End()
+
 	if err != nil {
 		logger.Err(err).Msg("failed to convert user to object")
 		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
@@ -96,19 +98,18 @@ func (u UsersResourceHandler) updateUser(
 
 	userObj.Properties = props
 
-	sourceUserResp, err := u.dirClient.DS().Writer.SetObject(ctx, &dsw.SetObjectRequest{
+	sourceUserResp, err := u.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
 		Object: userObj,
 	})
 	if err != nil {
 		logger.Err(err).Msg("failed to replace user")
-		return scim.Resource{}, err
+		return scim.Resource{}, handlers.VersionConflict(err)
 	}
 
-	meta, err := u.dirClient.SetUser(ctx, userObj.GetId(), transformResult, attr)
-	if err != nil {
+	if _, err := u.dirClient.SetUser(ctx, userObj.GetId(), transformResult, attr); err != nil {
 		logger.Err(err).Msg("failed to sync user")
 		return scim.Resource{}, err
 	}
 
-	return converter.ObjectToResource(sourceUserResp.GetResult(), meta), nil
+	return objectToResource(converter, sourceUserResp.GetResult()), nil
 }
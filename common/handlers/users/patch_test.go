@@ -0,0 +1,83 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/directory"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// newUsersHandler returns a users handler that transforms users with the default template into the directory.
+func newUsersHandler(t *testing.T, dir *directorytest.Directory) *UsersResourceHandler {
+	t.Helper()
+
+	cfg, err := convert.NewTransformConfig(&config.Config{
+		User: &config.User{
+			ObjectType:         "user",
+			IdentityObjectType: "identity",
+			IdentityRelation:   "user#identifier",
+			SourceObjectType:   "scim.2.0.user",
+			ManagerRelation:    "manager",
+		},
+		Group: &config.Group{ObjectType: "group", GroupMemberRelation: "member", SourceObjectType: "scim.2.0.group"},
+		Role:  &config.Role{ObjectType: "role", RoleRelation: "assignee"},
+	})
+	require.NoError(t, err)
+
+	validator := handlers.NewValidator(schema.CoreUserSchema(), scim.SchemaExtension{Schema: schema.ExtensionEnterpriseUser()})
+	logger := zerolog.Nop()
+
+	handler, err := NewUsersResourceHandler(&logger, cfg, dir.Client(), validator)
+	require.NoError(t, err)
+
+	return handler
+}
+
+func roleRelation(roleID, userID string) *dsc.Relation {
+	return &dsc.Relation{ObjectType: "role", ObjectId: roleID, Relation: "assignee", SubjectType: "user", SubjectId: userID}
+}
+
+func TestPatchKeepsRolesSetThroughRoles(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newUsersHandler(t, dir)
+	ctx := context.Background()
+
+	user, err := handler.Create(ctx, scim.ResourceAttributes{
+		"userName": "rick",
+		"active":   true,
+		"emails":   []any{map[string]any{"value": "rick@the-citadel.com", "type": "work"}},
+	})
+	assert.NoError(err)
+
+	dir.AddObject(&dsc.Object{Type: "role", Id: "admin", DisplayName: "Admin"})
+	dir.AddObject(&dsc.Object{Type: "role", Id: "scientist", DisplayName: "Scientist"})
+
+	roles := directory.NewDirectoryClient(handler.cfg, handler.logger, dir.Client())
+	assert.NoError(roles.SetRoleMembers(ctx, "admin", []string{user.ID}))
+	assert.NoError(roles.SetRoleMembers(ctx, "scientist", []string{user.ID}))
+
+	deactivate := []scim.PatchOperation{{Op: scim.PatchOperationReplace, Path: nil, Value: map[string]any{"active": false}}}
+
+	_, err = handler.Patch(ctx, user.ID, deactivate)
+	assert.NoError(err)
+	assert.Contains(dir.Relations, directorytest.RelationKey(roleRelation("admin", user.ID)))
+	assert.Contains(dir.Relations, directorytest.RelationKey(roleRelation("scientist", user.ID)))
+
+	assert.NoError(roles.SetRoleMembers(ctx, "admin", nil))
+
+	_, err = handler.Patch(ctx, user.ID, deactivate)
+	assert.NoError(err)
+	assert.NotContains(dir.Relations, directorytest.RelationKey(roleRelation("admin", user.ID)))
+	assert.Contains(dir.Relations, directorytest.RelationKey(roleRelation("scientist", user.ID)))
+}
@@ -3,23 +3,82 @@ package users
 import (
 	"context"
 
+	dsw "github.com/aserto-dev/go-directory/aserto/directory/writer/v3"
+	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
 )
 
+// Replace updates the user in place. The source object keeps its id and creation date, and the
+// directory client only applies the difference between the existing and the new user objects,
+// identities and relations.
 func (u UsersResourceHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
-	logger := u.logger.With().Str("method", "Replace").Str("id", id).Logger()
+	logger := u.logger.With().Ctx(ctx).Str("method", "Replace").Str("id", id).Logger()
 	logger.Info().Msg("replace user")
-	u.logger.Trace().Any("attributes", attributes).Msg("replacing user")
+	logger.Trace().Any("attributes", attributes).Msg("replacing user")
 
-	err := u.Delete(ctx, id)
+	sourceUser, err := u.getSourceUser(ctx, id)
 	if err != nil {
-		logger.Err(err).Msg("failed to delete user")
+		logger.Err(err).Msg("failed to get user")
 		return scim.Resource{}, err
 	}
 
-	resource, err := u.Create(ctx, attributes)
+	if sourceUser == nil {
+		return scim.Resource{}, serrors.ScimErrorResourceNotFound(id)
+	}
+
+	if err := handlers.CheckIfMatch(ctx, sourceUser.GetEtag()); err != nil {
+		logger.Err(err).Msg("precondition failed")
+		return scim.Resource{}, err
+	}
+
+	converter := convert.NewConverter(u.cfg)
+
+	if err := u.validator.ValidateUpdate(converter.ObjectToResourceAttributes(sourceUser), attributes); err != nil {
+		logger.Err(err).Msg("invalid user")
+		return scim.Resource{}, err
+	}
+
+	user, err := u.convertAttributesToUser(attributes, logger)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	_, convertSpan := handlers.StartSpan(ctx, "convert.SCIMUserToObject")
+	object, err := converter.SCIMUserToObject(user)
+
+	convertSpan.End()
+
+	if err != nil {
+		logger.Err(err).Msg("failed to convert user to object")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	if err := converter.SetExtensionProperties(object, attributes, u.cfg.User.SchemaExtensions); err != nil {
+		logger.Err(err).Msg("failed to convert user extensions")
+		return scim.Resource{}, serrors.ScimErrorInvalidSyntax
+	}
+
+	object.Id = sourceUser.GetId()
+	object.Etag = sourceUser.GetEtag()
+
+	var resource scim.Resource
+
+	err = u.dirClient.Atomic(ctx, func(ctx context.Context) error {
+		sourceUserResp, err := u.dirClient.SetObject(ctx, &dsw.SetObjectRequest{
+			Object: object,
+		})
+		if err != nil {
+			logger.Err(err).Msg("failed to replace user")
+			return handlers.VersionConflict(err)
+		}
+
+		resource, err = u.processUserResponse(ctx, sourceUserResp, attributes, converter, logger)
+
+		return err
+	})
 	if err != nil {
-		logger.Err(err).Msg("failed to create user")
 		return scim.Resource{}, err
 	}
 
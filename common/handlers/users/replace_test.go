@@ -0,0 +1,74 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/directory/directorytest"
+	"github.com/elimity-com/scim"
+	"github.com/stretchr/testify/require"
+)
+
+const enterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+func identityRelation(userID, identity string) *dsc.Relation {
+	return &dsc.Relation{ObjectType: "user", ObjectId: userID, Relation: "identifier", SubjectType: "identity", SubjectId: identity}
+}
+
+func TestReplaceInPlace(t *testing.T) {
+	assert := require.New(t)
+
+	dir := directorytest.NewDirectory()
+	handler := newUsersHandler(t, dir)
+	ctx := context.Background()
+
+	user, err := handler.Create(ctx, scim.ResourceAttributes{
+		"userName": "rick",
+		"emails": []any{
+			map[string]any{"value": "rick@the-citadel.com", "type": "work"},
+			map[string]any{"value": "rick@home.org", "type": "home"},
+		},
+		enterpriseUser: map[string]any{"manager": map[string]any{"value": "morty"}},
+	})
+	assert.NoError(err)
+	assert.NotNil(user.Meta.Created)
+
+	manager := &dsc.Relation{ObjectType: "user", ObjectId: user.ID, Relation: "manager", SubjectType: "user", SubjectId: "morty"}
+	member := &dsc.Relation{ObjectType: "group", ObjectId: "council", Relation: "member", SubjectType: "user", SubjectId: user.ID}
+	owner := &dsc.Relation{ObjectType: "device", ObjectId: "portal-gun", Relation: "owner", SubjectType: "user", SubjectId: user.ID}
+
+	assert.Contains(dir.Relations, directorytest.RelationKey(manager))
+	assert.Contains(dir.Relations, directorytest.RelationKey(identityRelation(user.ID, "rick@home.org")))
+
+	dir.AddObject(&dsc.Object{Type: "group", Id: "council"})
+	dir.AddRelation(member)
+	dir.AddRelation(owner)
+
+	replaced, err := handler.Replace(ctx, user.ID, scim.ResourceAttributes{
+		"userName":     "rick",
+		"displayName":  "Rick Sanchez",
+		"emails":       []any{map[string]any{"value": "rick@the-citadel.com", "type": "work"}},
+		enterpriseUser: map[string]any{"manager": map[string]any{"value": "morty"}},
+	})
+	assert.NoError(err)
+	assert.Equal(user.ID, replaced.ID)
+	assert.Equal("Rick Sanchez", replaced.Attributes["displayName"])
+	assert.Equal(user.Meta.Created, replaced.Meta.Created)
+	assert.NotEqual(user.Meta.Version, replaced.Meta.Version)
+
+	assert.Contains(dir.Relations, directorytest.RelationKey(manager))
+	assert.Contains(dir.Relations, directorytest.RelationKey(member))
+	assert.Contains(dir.Relations, directorytest.RelationKey(owner))
+	assert.Contains(dir.Relations, directorytest.RelationKey(identityRelation(user.ID, "rick@the-citadel.com")))
+	assert.Equal("Rick Sanchez", dir.Objects["user:"+user.ID].GetDisplayName())
+
+	assert.NotContains(dir.Relations, directorytest.RelationKey(identityRelation(user.ID, "rick@home.org")))
+	assert.NotContains(dir.Objects, "identity:rick@home.org")
+	assert.Contains(dir.Objects, "identity:rick@the-citadel.com")
+
+	resource, err := handler.Get(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal(user.Meta.Created, resource.Meta.Created)
+	assert.Len(resource.Attributes["groups"], 1)
+}
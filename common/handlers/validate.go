@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+)
+
+const (
+	mutabilityReadOnly  = "readOnly"
+	mutabilityImmutable = "immutable"
+)
+
+// Validator checks resource attributes against the schema and schema extensions of a resource type.
+type Validator struct {
+	schema     schema.Schema
+	extensions []scim.SchemaExtension
+}
+
+func NewValidator(resourceSchema schema.Schema, extensions ...scim.SchemaExtension) *Validator {
+	return &Validator{
+		schema:     resourceSchema,
+		extensions: extensions,
+	}
+}
+
+// Validate checks the data types, required attributes and canonical values of the resource attributes.
+// Attributes that are not defined by the schemas are ignored.
+func (v *Validator) Validate(attributes scim.ResourceAttributes) error {
+	resource, err := normalize(attributes)
+	if err != nil {
+		return serrors.ScimErrorInvalidSyntax
+	}
+
+	if err := validateAttributes("", v.schema.Attributes, resource); err != nil {
+		return err
+	}
+
+	for _, extension := range v.extensions {
+		value := attributeValue(resource, extension.Schema.ID)
+		if value == nil {
+			if extension.Required {
+				return invalidValue(fmt.Sprintf("The schema extension %q is required.", extension.Schema.ID))
+			}
+
+			continue
+		}
+
+		extensionAttributes, ok := value.(map[string]any)
+		if !ok {
+			return invalidValue(fmt.Sprintf("The schema extension %q must be an object.", extension.Schema.ID))
+		}
+
+		if err := validateAttributes(extension.Schema.ID+":", extension.Schema.Attributes, extensionAttributes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateUpdate validates the attributes that replace the current attributes of a resource, in a PUT request
+// or as the result of a PATCH request.
+func (v *Validator) ValidateUpdate(current, updated scim.ResourceAttributes) error {
+	if err := v.Validate(updated); err != nil {
+		return err
+	}
+
+	return v.CheckMutability(current, updated)
+}
+
+// CheckMutability returns a mutability error if updated modifies an immutable attribute that has a value in
+// current. ReadOnly attributes are removed from updated, as their values are ignored (RFC 7644 section 3.5.1).
+// Immutable sub-attributes of multi-valued attributes are not checked, as their values can only be added or
+// removed as a whole.
+func (v *Validator) CheckMutability(current, updated scim.ResourceAttributes) error {
+	dropReadOnly(v.schema.Attributes, updated)
+
+	for _, extension := range v.extensions {
+		if extensionAttributes, ok := attributeValue(updated, extension.Schema.ID).(map[string]any); ok {
+			dropReadOnly(extension.Schema.Attributes, extensionAttributes)
+		}
+	}
+
+	currentResource, err := normalize(current)
+	if err != nil {
+		return err
+	}
+
+	updatedResource, err := normalize(updated)
+	if err != nil {
+		return serrors.ScimErrorInvalidSyntax
+	}
+
+	if err := checkMutability("", v.schema.Attributes, currentResource, updatedResource); err != nil {
+		return err
+	}
+
+	for _, extension := range v.extensions {
+		currentExtension, _ := attributeValue(currentResource, extension.Schema.ID).(map[string]any)
+		updatedExtension, _ := attributeValue(updatedResource, extension.Schema.ID).(map[string]any)
+
+		if err := checkMutability(extension.Schema.ID+":", extension.Schema.Attributes, currentExtension, updatedExtension); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CaseExact reports whether the values of the attribute at path, such as "userName" or "members.value", are
+// compared case-sensitively. Besides the attributes the schemas declare case exact, these are the common id and
+// externalId attributes and the values of references to other resources, which hold resource ids.
+func (v *Validator) CaseExact(path string) bool {
+	attributes := v.schema.Attributes
+
+	for _, extension := range v.extensions {
+		prefix := extension.Schema.ID + ":"
+		if len(path) > len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			attributes = extension.Schema.Attributes
+			path = path[len(prefix):]
+
+			break
+		}
+	}
+
+	name, sub, _ := strings.Cut(path, ".")
+
+	if sub == "" && (strings.EqualFold(name, schema.CommonAttributeID) || strings.EqualFold(name, schema.CommonAttributeExternalID)) {
+		return true
+	}
+
+	attribute, ok := attributes.ContainsAttribute(name)
+	if !ok {
+		return false
+	}
+
+	if sub == "" {
+		return attribute.CaseExact()
+	}
+
+	subAttribute, ok := attribute.SubAttributes().ContainsAttribute(sub)
+	if !ok {
+		return false
+	}
+
+	if _, isReference := attribute.SubAttributes().ContainsAttribute("$ref"); isReference && strings.EqualFold(sub, "value") {
+		return true
+	}
+
+	return subAttribute.CaseExact()
+}
+
+func validateAttributes(prefix string, attributes schema.Attributes, resource map[string]any) error {
+	for _, attribute := range attributes {
+		value := attributeValue(resource, attribute.Name())
+
+		// Validate the attributes one at a time, so that the error can name the offending attribute.
+		single := schema.Schema{Attributes: schema.Attributes{attribute}}
+		if _, scimErr := single.Validate(map[string]any{attribute.Name(): value}); scimErr != nil {
+			return serrors.ScimError{
+				ScimType: scimErr.ScimType,
+				Detail:   fmt.Sprintf("%s (attribute %q)", scimErr.Detail, prefix+attribute.Name()),
+				Status:   scimErr.Status,
+			}
+		}
+
+		if attribute.Mutability() == mutabilityReadOnly {
+			continue
+		}
+
+		if err := checkCanonicalValues(prefix+attribute.Name(), attribute, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCanonicalValues verifies that the values of attributes with canonical values, such as the type of an
+// email, are one of those values. Empty values are accepted.
+func checkCanonicalValues(path string, attribute schema.CoreAttribute, value any) error {
+	values, ok := value.([]any)
+	if !ok || !attribute.MultiValued() {
+		values = []any{value}
+	}
+
+	for _, v := range values {
+		if attribute.HasSubAttributes() {
+			complexValue, _ := v.(map[string]any)
+
+			for _, sub := range attribute.SubAttributes() {
+				if err := checkCanonicalValues(path+"."+sub.Name(), sub, attributeValue(complexValue, sub.Name())); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		canonicalValues := attribute.CanonicalValues()
+
+		s, ok := v.(string)
+		if len(canonicalValues) == 0 || !ok || s == "" {
+			continue
+		}
+
+		if !slices.ContainsFunc(canonicalValues, func(c string) bool {
+			return c == s || !attribute.CaseExact() && strings.EqualFold(c, s)
+		}) {
+			return invalidValue(fmt.Sprintf("The value %q of attribute %q must be one of %s.",
+				s, path, strings.Join(canonicalValues, ", ")))
+		}
+	}
+
+	return nil
+}
+
+func checkMutability(prefix string, attributes schema.Attributes, current, updated map[string]any) error {
+	for _, attribute := range attributes {
+		currentValue := attributeValue(current, attribute.Name())
+		updatedValue := attributeValue(updated, attribute.Name())
+		path := prefix + attribute.Name()
+
+		if attribute.Mutability() == mutabilityImmutable && currentValue != nil && !reflect.DeepEqual(currentValue, updatedValue) {
+			return mutabilityError(fmt.Sprintf("The attribute %q is immutable.", path))
+		}
+
+		if attribute.MultiValued() || !attribute.HasSubAttributes() {
+			continue
+		}
+
+		currentComplex, _ := currentValue.(map[string]any)
+		updatedComplex, _ := updatedValue.(map[string]any)
+
+		if err := checkMutability(path+".", attribute.SubAttributes(), currentComplex, updatedComplex); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropReadOnly removes the readOnly attributes, and readOnly sub-attributes of complex attributes, from values.
+func dropReadOnly(attributes schema.Attributes, values map[string]any) {
+	for _, attribute := range attributes {
+		for key, value := range values {
+			if !strings.EqualFold(key, attribute.Name()) {
+				continue
+			}
+
+			if attribute.Mutability() == mutabilityReadOnly {
+				delete(values, key)
+				continue
+			}
+
+			if complexValue, ok := value.(map[string]any); ok && !attribute.MultiValued() && attribute.HasSubAttributes() {
+				dropReadOnly(attribute.SubAttributes(), complexValue)
+			}
+		}
+	}
+}
+
+// attributeValue returns the value of an attribute, matching its name case-insensitively.
+func attributeValue(attributes map[string]any, name string) any {
+	if value, ok := attributes[name]; ok {
+		return value
+	}
+
+	for key, value := range attributes {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// normalize converts attributes read from the directory or produced by a PATCH to the representation of a
+// decoded request body, in which numbers are json.Number values.
+func normalize(attributes scim.ResourceAttributes) (map[string]any, error) {
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var resource map[string]any
+	if err := decoder.Decode(&resource); err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func invalidValue(detail string) serrors.ScimError {
+	return serrors.ScimError{
+		ScimType: serrors.ScimTypeInvalidValue,
+		Detail:   detail,
+		Status:   http.StatusBadRequest,
+	}
+}
+
+func mutabilityError(detail string) serrors.ScimError {
+	return serrors.ScimError{
+		ScimType: serrors.ScimTypeMutability,
+		Detail:   detail,
+		Status:   http.StatusBadRequest,
+	}
+}
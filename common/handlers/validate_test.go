@@ -0,0 +1,176 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	validator := handlers.NewValidator(schema.CoreUserSchema(), scim.SchemaExtension{Schema: schema.ExtensionEnterpriseUser()})
+
+	tests := []struct {
+		name       string
+		attributes scim.ResourceAttributes
+		scimType   serrors.ScimType
+	}{
+		{
+			name: "valid",
+			attributes: scim.ResourceAttributes{
+				"userName": "rick",
+				"emails":   []any{map[string]any{"value": "rick@the-citadel.com", "type": "Work"}},
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]any{"employeeNumber": "C-137"},
+			},
+		},
+		{
+			name:       "missing required attribute",
+			attributes: scim.ResourceAttributes{"displayName": "Rick"},
+			scimType:   serrors.ScimTypeInvalidValue,
+		},
+		{
+			name:       "wrong type",
+			attributes: scim.ResourceAttributes{"userName": "rick", "active": "yes"},
+			scimType:   serrors.ScimTypeInvalidValue,
+		},
+		{
+			name: "non-canonical value",
+			attributes: scim.ResourceAttributes{
+				"userName": "rick",
+				"emails":   []any{map[string]any{"value": "rick@the-citadel.com", "type": "portal"}},
+			},
+			scimType: serrors.ScimTypeInvalidValue,
+		},
+		{
+			name: "invalid extension",
+			attributes: scim.ResourceAttributes{
+				"userName": "rick",
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]any{"employeeNumber": 137},
+			},
+			scimType: serrors.ScimTypeInvalidValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			err := validator.Validate(tc.attributes)
+			if tc.scimType == "" {
+				assert.NoError(err)
+				return
+			}
+
+			var scimErr serrors.ScimError
+
+			assert.ErrorAs(err, &scimErr)
+			assert.Equal(tc.scimType, scimErr.ScimType)
+		})
+	}
+}
+
+func TestCheckMutability(t *testing.T) {
+	assert := require.New(t)
+
+	badge := schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+		Name:       "badge",
+		Mutability: schema.AttributeMutabilityImmutable(),
+	}))
+
+	validator := handlers.NewValidator(schema.Schema{
+		ID:         "urn:example:Badge",
+		Attributes: append(schema.CoreUserSchema().Attributes, badge),
+	})
+
+	current := scim.ResourceAttributes{"userName": "rick"}
+
+	assert.NoError(validator.CheckMutability(current, scim.ResourceAttributes{"userName": "rick", "badge": "C-137"}))
+
+	current["badge"] = "C-137"
+
+	assert.NoError(validator.CheckMutability(current, scim.ResourceAttributes{"userName": "morty", "badge": "C-137"}))
+	assert.Error(validator.CheckMutability(current, scim.ResourceAttributes{"userName": "rick", "badge": "C-138"}))
+	assert.Error(validator.CheckMutability(current, scim.ResourceAttributes{"userName": "rick"}))
+
+	updated := scim.ResourceAttributes{
+		"userName": "rick",
+		"badge":    "C-137",
+		"groups":   []any{map[string]any{"value": "council"}},
+	}
+
+	assert.NoError(validator.CheckMutability(current, updated))
+	assert.NotContains(updated, "groups")
+}
+
+func TestValidateUpdateRoundTrip(t *testing.T) {
+	assert := require.New(t)
+
+	validator := handlers.NewValidator(schema.CoreUserSchema(), scim.SchemaExtension{Schema: schema.ExtensionEnterpriseUser()})
+
+	// The stored attributes of a user don't hold its groups, which are derived from the group member relations.
+	current := scim.ResourceAttributes{
+		"userName": "rick@the-citadel.com",
+		"name":     map[string]any{"givenName": "Rick", "familyName": "Sanchez"},
+		"emails":   []any{map[string]any{"value": "rick@the-citadel.com", "primary": true}},
+	}
+
+	resource := scim.ResourceAttributes{
+		"id":     "rick",
+		"meta":   map[string]any{"resourceType": "User", "version": "W/\"1\""},
+		"groups": []any{map[string]any{"value": "council", "$ref": "Groups/council", "display": "council", "type": "direct"}},
+	}
+	for name, value := range current {
+		resource[name] = value
+	}
+
+	// An identity provider sends back the body it read, with the read-only attributes.
+	body, err := json.Marshal(resource)
+	assert.NoError(err)
+
+	var updated scim.ResourceAttributes
+	assert.NoError(json.Unmarshal(body, &updated))
+
+	assert.NoError(validator.ValidateUpdate(current, updated))
+	assert.NotContains(updated, "groups")
+	assert.Equal("rick@the-citadel.com", updated["userName"])
+}
+
+func TestCaseExact(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	users := handlers.NewValidator(schema.CoreUserSchema(), scim.SchemaExtension{Schema: schema.ExtensionEnterpriseUser()})
+	groups := handlers.NewValidator(schema.CoreGroupSchema())
+	devices := handlers.NewValidator(schema.Schema{
+		Attributes: schema.Attributes{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "serialNumber", CaseExact: true})),
+		},
+	})
+
+	tests := []struct {
+		name      string
+		validator *handlers.Validator
+		path      string
+		caseExact bool
+	}{
+		{name: "id", validator: users, path: "id", caseExact: true},
+		{name: "externalId", validator: users, path: "EXTERNALID", caseExact: true},
+		{name: "case exact attribute", validator: devices, path: "serialNumber", caseExact: true},
+		{name: "case insensitive attribute", validator: users, path: "userName"},
+		{name: "case insensitive sub-attribute", validator: users, path: "emails.value"},
+		{name: "reference value", validator: groups, path: "members.value", caseExact: true},
+		{name: "reference display", validator: groups, path: "members.display"},
+		{name: "extension reference value", validator: users, path: enterprise + ":manager.value", caseExact: true},
+		{name: "extension attribute", validator: users, path: enterprise + ":employeeNumber"},
+		{name: "unknown attribute", validator: groups, path: "owner"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.caseExact, tc.validator.CaseExact(tc.path))
+		})
+	}
+}
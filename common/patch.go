@@ -1,334 +1,767 @@
 package common
 
 import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
 	"github.com/elimity-com/scim"
 	serrors "github.com/elimity-com/scim/errors"
 	"github.com/scim2/filter-parser/v2"
 )
 
-func HandlePatchOPAdd(objectProps scim.ResourceAttributes, op scim.PatchOperation) (scim.ResourceAttributes, error) {
-	if op.Path == nil || op.Path.ValueExpression == nil {
-		return AddProperty(objectProps, op)
-	}
+// coreSchemaPrefix is the URN prefix of the core User and Group schemas. Attributes of these schemas live at
+// the top level of the resource attributes, attributes of any other schema live under the schema URN.
+const coreSchemaPrefix = "urn:ietf:params:scim:schemas:core:"
+
+// CaseExactFunc reports whether the values of the attribute at path, such as "members.value", are compared
+// case-sensitively. The path of an extension attribute is prefixed with the extension URN.
+type CaseExactFunc func(path string) bool
+
+// ApplyPatch applies the PATCH operations in order to a copy of the resource attributes. Either all operations
+// are applied and the patched copy is returned, or an error is returned and attributes are left untouched.
+// Value filters compare the attributes for which caseExact returns true case-sensitively, and all others
+// case-insensitively; caseExact may be nil.
+func ApplyPatch(
+	attributes scim.ResourceAttributes,
+	operations []scim.PatchOperation,
+	caseExact CaseExactFunc,
+) (scim.ResourceAttributes, error) {
+	result, _ := deepCopy(map[string]any(attributes)).(map[string]any)
 
-	fltr, err := parseValueExpression(op.Path.ValueExpression)
-	if err != nil {
-		return nil, err
-	}
+	for _, op := range operations {
+		var err error
+
+		switch op.Op {
+		case scim.PatchOperationAdd:
+			result, err = HandlePatchOPAdd(result, op, caseExact)
+		case scim.PatchOperationRemove:
+			result, err = HandlePatchOPRemove(result, op, caseExact)
+		case scim.PatchOperationReplace:
+			result, err = HandlePatchOPReplace(result, op, caseExact)
+		default:
+			err = serrors.ScimErrorInvalidValue
+		}
 
-	if op.Path.ValueExpression == nil {
-		return handleSimpleAdd(objectProps, op)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return handleComplexAdd(objectProps, op, fltr)
+	return result, nil
 }
 
-func parseValueExpression(expr any) (*filter.AttributeExpression, error) {
-	valueExpression, ok := expr.(*filter.AttributeExpression)
-	if !ok {
-		return nil, serrors.ScimErrorInvalidPath
-	}
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = deepCopy(item)
+		}
 
-	fltr, err := filter.ParseAttrExp([]byte(valueExpression.String()))
-	if err != nil {
-		return nil, err
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+
+		return result
+	default:
+		return value
 	}
+}
 
-	return &fltr, nil
+// patchTarget is the location a PATCH path points at: an attribute of a container, optionally narrowed down to
+// the values of a multi-valued attribute matching a filter and to a sub-attribute of those values.
+type patchTarget struct {
+	container map[string]any
+	name      string
+	filter    filter.Expression
+	sub       string
+	path      string
+	caseExact CaseExactFunc
 }
 
-func handleSimpleAdd(objectProps scim.ResourceAttributes, op scim.PatchOperation) (scim.ResourceAttributes, error) {
-	properties := make(map[string]any)
-	properties[*op.Path.SubAttribute] = op.Value
+func (t *patchTarget) value() any {
+	return t.container[t.name]
+}
 
-	return objectProps, nil
+func (t *patchTarget) set(value any) {
+	t.container[t.name] = value
 }
 
-func handleComplexAdd(objectProps scim.ResourceAttributes,
-	op scim.PatchOperation,
-	fltr *filter.AttributeExpression,
-) (scim.ResourceAttributes, error) {
-	attrName := op.Path.AttributePath.AttributeName
+// exact reports whether the attribute of the target values that a filter refers to is case exact.
+func (t *patchTarget) exact(path filter.AttributePath) bool {
+	return t.caseExact != nil && t.caseExact(t.path+"."+path.AttributeName)
+}
+
+// resolveTarget resolves a PATCH path against the resource attributes. Extension attributes are looked up in
+// the object stored under the extension URN, which is created if create is set. A nil target means the path
+// points into an extension the resource does not have.
+func resolveTarget(attributes map[string]any, path *filter.Path, create bool, caseExact CaseExactFunc) *patchTarget {
+	container := attributes
+	name := path.AttributePath.AttributeName
+	attrPath := name
+
+	if uri := path.AttributePath.URI(); uri != "" && !strings.HasPrefix(uri, coreSchemaPrefix) {
+		attrPath = uri + ":" + name
+
+		// A path consisting of an extension URN only refers to the extension object itself.
+		if key := findKey(attributes, attrPath); attributes[key] != nil || isSchemaName(name) {
+			return &patchTarget{
+				container: attributes,
+				name:      key,
+				filter:    path.ValueExpression,
+				sub:       subAttribute(path),
+				path:      attrPath,
+				caseExact: caseExact,
+			}
+		}
+
+		key := findKey(attributes, uri)
+
+		extension, ok := attributes[key].(map[string]any)
+		if !ok {
+			if !create {
+				return nil
+			}
+
+			extension = map[string]any{}
+			attributes[key] = extension
+		}
 
-	if objectProps[attrName] == nil {
-		objectProps[attrName] = make([]any, 0)
+		container = extension
 	}
 
-	properties := make(map[string]any)
+	return &patchTarget{
+		container: container,
+		name:      findKey(container, name),
+		filter:    path.ValueExpression,
+		sub:       subAttribute(path),
+		path:      attrPath,
+		caseExact: caseExact,
+	}
+}
 
-	if objectProps[attrName] != nil {
-		var err error
+// isSchemaName reports whether the last segment of a URN is the name of a schema, such as the "User" of the
+// enterprise user extension, rather than the name of an attribute. Schema names are capitalized, while
+// attribute names start with a lowercase letter.
+func isSchemaName(name string) bool {
+	return name != "" && unicode.IsUpper(rune(name[0]))
+}
 
-		properties, err = processExistingAttributes(objectProps[attrName], op, fltr)
-		if err != nil {
-			return nil, err
+// pathlessTarget resolves the name of an attribute in the value of an add or replace operation without a path.
+// Names qualified with a schema URN resolve like a path does, so that e.g. the enterprise manager can be set
+// with "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager".
+func pathlessTarget(attributes map[string]any, name string, caseExact CaseExactFunc) *patchTarget {
+	if strings.Contains(name, ":") {
+		if path, err := filter.ParsePath([]byte(name)); err == nil && path.ValueExpression == nil {
+			return resolveTarget(attributes, &path, true, caseExact)
 		}
 	}
 
-	if len(properties) == 0 {
-		return appendNewProperty(objectProps, op, fltr)
+	return &patchTarget{container: attributes, name: findKey(attributes, name), path: name, caseExact: caseExact}
+}
+
+func subAttribute(path *filter.Path) string {
+	if path.SubAttribute != nil {
+		return *path.SubAttribute
 	}
 
-	return objectProps, nil
+	if path.AttributePath.SubAttribute != nil {
+		return *path.AttributePath.SubAttribute
+	}
+
+	return ""
 }
 
-func processExistingAttributes(attr any, op scim.PatchOperation, fltr *filter.AttributeExpression) (map[string]any, error) {
-	attrProps, ok := attr.([]any)
-	if !ok {
-		return nil, serrors.ScimErrorInvalidPath
+// findKey returns the key of the attribute with the given name, which is matched case-insensitively.
+// If the attribute does not exist, name is returned as is.
+func findKey(attributes map[string]any, name string) string {
+	if _, ok := attributes[name]; ok {
+		return name
+	}
+
+	for key := range attributes {
+		if strings.EqualFold(key, name) {
+			return key
+		}
 	}
 
-	for _, v := range attrProps {
-		originalValue, ok := v.(map[string]any)
+	return name
+}
+
+// HandlePatchOPAdd applies an add operation. Values are appended to multi-valued attributes, merged into complex
+// attributes and replace simple attributes. Without a path, the value is an object of attributes to add.
+func HandlePatchOPAdd(
+	objectProps scim.ResourceAttributes,
+	op scim.PatchOperation,
+	caseExact CaseExactFunc,
+) (scim.ResourceAttributes, error) {
+	if op.Path == nil {
+		values, ok := op.Value.(map[string]any)
 		if !ok {
-			return nil, serrors.ScimErrorInvalidPath
+			return nil, serrors.ScimErrorInvalidValue
 		}
 
-		if result, err := processAttribute(originalValue, op, fltr); err != nil {
-			return nil, err
-		} else if result != nil {
-			return result, nil
+		for name, value := range values {
+			target := pathlessTarget(objectProps, name, caseExact)
+			if target.sub != "" {
+				if err := setSubAttribute(target, value); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			target.set(addValue(target.value(), value))
 		}
+
+		return objectProps, nil
 	}
 
-	return make(map[string]any), nil
+	target := resolveTarget(objectProps, op.Path, true, caseExact)
+
+	switch {
+	case target.filter != nil:
+		return objectProps, addToMatches(target, op.Value)
+	case target.sub != "":
+		return objectProps, setSubAttribute(target, op.Value)
+	default:
+		target.set(addValue(target.value(), op.Value))
+	}
+
+	return objectProps, nil
 }
 
-func processAttribute(value map[string]any, op scim.PatchOperation, fltr *filter.AttributeExpression) (map[string]any, error) {
-	switch fltr.Operator {
-	case filter.EQ:
-		return processEqualityOperator(value, op, fltr)
-	case filter.PR, filter.NE, filter.CO, filter.SW, filter.EW, filter.GT, filter.GE, filter.LT, filter.LE:
-		return nil, serrors.ScimErrorBadRequest("operand not supported")
+// addValue adds value to the current value of an attribute.
+func addValue(current, value any) any {
+	switch c := current.(type) {
+	case []any:
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+
+		for _, v := range values {
+			if !slices.ContainsFunc(c, func(item any) bool { return reflect.DeepEqual(item, v) }) {
+				c = append(c, v)
+			}
+		}
+
+		return c
+	case map[string]any:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return value
+		}
+
+		for name, v := range values {
+			key := findKey(c, name)
+			c[key] = addValue(c[key], v)
+		}
+
+		return c
 	default:
-		return nil, nil
+		return value
 	}
 }
 
-func processEqualityOperator(value map[string]any, op scim.PatchOperation, fltr *filter.AttributeExpression) (map[string]any, error) {
-	attrValue, ok := value[fltr.AttributePath.AttributeName].(string)
-	if !ok || attrValue != fltr.CompareValue {
-		return nil, nil
+// addToMatches adds value to the values of a multi-valued attribute that match the target filter. If none match
+// and the filter is an equality filter, a new value satisfying the filter is appended, so that clients can set
+// e.g. emails[type eq "work"].value on a user without a work email.
+func addToMatches(target *patchTarget, value any) error {
+	values, matches, err := matchValues(target)
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		element, ok := newMatchingValue(target.filter)
+		if !ok {
+			return serrors.ScimErrorNoTarget
+		}
+
+		if target.sub != "" {
+			element[target.sub] = value
+		} else if v, ok := value.(map[string]any); ok {
+			addValue(element, v)
+		} else {
+			return serrors.ScimErrorInvalidValue
+		}
+
+		target.set(append(values, element))
+
+		return nil
 	}
 
-	if value[*op.Path.SubAttribute] != nil {
-		return nil, serrors.ScimErrorUniqueness
+	for _, i := range matches {
+		element, ok := values[i].(map[string]any)
+		if !ok {
+			return serrors.ScimErrorInvalidPath
+		}
+
+		if target.sub != "" {
+			key := findKey(element, target.sub)
+			element[key] = addValue(element[key], value)
+		} else {
+			values[i] = addValue(element, value)
+		}
 	}
 
-	return value, nil
+	return nil
 }
 
-func appendNewProperty(objectProps scim.ResourceAttributes,
+func newMatchingValue(expr filter.Expression) (map[string]any, bool) {
+	attrExpr, ok := expr.(*filter.AttributeExpression)
+	if !ok || attrExpr.Operator != filter.EQ || attrExpr.AttributePath.SubAttribute != nil {
+		return nil, false
+	}
+
+	return map[string]any{attrExpr.AttributePath.AttributeName: attrExpr.CompareValue}, true
+}
+
+// HandlePatchOPRemove applies a remove operation. Removing an attribute, sub-attribute or value that does not
+// exist succeeds without changes.
+func HandlePatchOPRemove(
+	objectProps scim.ResourceAttributes,
 	op scim.PatchOperation,
-	fltr *filter.AttributeExpression,
+	caseExact CaseExactFunc,
 ) (scim.ResourceAttributes, error) {
-	properties := map[string]any{
-		fltr.AttributePath.AttributeName: fltr.CompareValue,
-		*op.Path.SubAttribute:            op.Value,
+	if op.Path == nil {
+		return nil, serrors.ScimErrorNoTarget
 	}
 
-	attrProps, ok := objectProps[op.Path.AttributePath.AttributeName].([]any)
-	if !ok {
-		return nil, serrors.ScimErrorInvalidPath
+	target := resolveTarget(objectProps, op.Path, false, caseExact)
+	if target == nil || target.value() == nil {
+		return objectProps, nil
 	}
 
-	objectProps[op.Path.AttributePath.AttributeName] = append(attrProps, properties)
+	switch {
+	case target.filter != nil:
+		return objectProps, removeMatches(target)
+	case target.sub != "":
+		removeSubAttribute(target)
+	case op.Value != nil:
+		return objectProps, removeValues(target, op.Value)
+	default:
+		delete(target.container, target.name)
+	}
 
 	return objectProps, nil
 }
 
-func HandlePatchOPRemove(objectProps scim.ResourceAttributes, op scim.PatchOperation) (scim.ResourceAttributes, error) {
-	var err error
+func removeMatches(target *patchTarget) error {
+	values, matches, err := matchValues(target)
+	if err != nil {
+		return err
+	}
 
-	switch value := objectProps[op.Path.AttributePath.AttributeName].(type) {
-	case string:
-		delete(objectProps, op.Path.AttributePath.AttributeName)
+	if target.sub != "" {
+		for _, i := range matches {
+			if element, ok := values[i].(map[string]any); ok {
+				delete(element, findKey(element, target.sub))
+			}
+		}
+
+		return nil
+	}
+
+	remaining := make([]any, 0, len(values))
+
+	for i, v := range values {
+		if !slices.Contains(matches, i) {
+			remaining = append(remaining, v)
+		}
+	}
+
+	setOrDelete(target, remaining)
+
+	return nil
+}
+
+func removeSubAttribute(target *patchTarget) {
+	switch v := target.value().(type) {
+	case map[string]any:
+		delete(v, findKey(v, target.sub))
 	case []any:
-		objectProps, err = patchOrRemoveSlice(value, op, objectProps)
-		if err != nil {
-			return nil, err
+		for _, item := range v {
+			if element, ok := item.(map[string]any); ok {
+				delete(element, findKey(element, target.sub))
+			}
 		}
 	}
+}
 
-	return objectProps, err
+// removeValues removes the given values from a multi-valued attribute. Complex values are identified by their
+// "value" sub-attribute, as in a remove of group members with a list of {"value": id} objects.
+func removeValues(target *patchTarget, value any) error {
+	current, ok := target.value().([]any)
+	if !ok {
+		return serrors.ScimErrorInvalidPath
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	remaining := slices.DeleteFunc(current, func(item any) bool {
+		return slices.ContainsFunc(values, func(v any) bool { return sameValue(item, v) })
+	})
+
+	setOrDelete(target, remaining)
+
+	return nil
+}
+
+func sameValue(a, b any) bool {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+
+	if aok && bok && bm["value"] != nil {
+		return reflect.DeepEqual(am["value"], bm["value"])
+	}
+
+	return reflect.DeepEqual(a, b)
 }
 
-func HandlePatchOPReplace(objectProps scim.ResourceAttributes, op scim.PatchOperation) (scim.ResourceAttributes, error) {
-	var err error
+// setOrDelete sets a multi-valued attribute, removing it when no values are left.
+func setOrDelete(target *patchTarget, values []any) {
+	if len(values) == 0 {
+		delete(target.container, target.name)
+		return
+	}
+
+	target.set(values)
+}
 
+// HandlePatchOPReplace applies a replace operation. A filter that matches no values fails with noTarget,
+// replacing an attribute that does not exist adds it. Without a path, the value is an object of attributes
+// to replace.
+func HandlePatchOPReplace(
+	objectProps scim.ResourceAttributes,
+	op scim.PatchOperation,
+	caseExact CaseExactFunc,
+) (scim.ResourceAttributes, error) {
 	if op.Path == nil {
-		value, ok := op.Value.(map[string]any)
-		if ok {
-			for k, v := range value {
-				objectProps[k] = v
+		values, ok := op.Value.(map[string]any)
+		if !ok {
+			return nil, serrors.ScimErrorInvalidValue
+		}
+
+		for name, value := range values {
+			target := pathlessTarget(objectProps, name, caseExact)
+			if target.sub != "" {
+				if err := setSubAttribute(target, value); err != nil {
+					return nil, err
+				}
+
+				continue
 			}
+
+			target.set(replaceValue(target.value(), value))
 		}
 
 		return objectProps, nil
 	}
 
-	switch value := objectProps[op.Path.AttributePath.AttributeName].(type) {
-	case string:
-		objectProps[op.Path.AttributePath.AttributeName] = op.Value
-	case map[string]any:
-		if op.Path.AttributePath.SubAttribute != nil {
-			value[*op.Path.AttributePath.SubAttribute] = op.Value
-		} else {
-			objectProps[op.Path.AttributePath.AttributeName] = op.Value
-		}
-	case []any:
-		if op.Path.ValueExpression == nil {
-			objectProps[op.Path.AttributePath.AttributeName] = op.Value
-			break
-		}
+	target := resolveTarget(objectProps, op.Path, true, caseExact)
 
-		value, err := ReplaceInInterfaceArray(value, op)
-		if err != nil {
-			return nil, err
+	switch {
+	case target.filter != nil:
+		return objectProps, replaceMatches(target, op.Value)
+	case target.sub != "":
+		return objectProps, setSubAttribute(target, op.Value)
+	default:
+		if _, ok := target.value().([]any); ok {
+			if _, ok := op.Value.([]any); !ok {
+				target.set([]any{op.Value})
+				break
+			}
 		}
 
-		objectProps[op.Path.AttributePath.AttributeName] = value
+		target.set(replaceValue(target.value(), op.Value))
 	}
 
-	return objectProps, err
+	return objectProps, nil
 }
 
-func ReplaceInInterfaceArray(value []any, op scim.PatchOperation) ([]any, error) {
-	attrExpr, ok := op.Path.ValueExpression.(*filter.AttributeExpression)
+// replaceValue replaces the current value of an attribute. The sub-attributes of complex attributes, such as
+// the attributes of an extension, are replaced individually.
+func replaceValue(current, value any) any {
+	c, ok := current.(map[string]any)
 	if !ok {
-		return nil, serrors.ScimErrorInvalidPath
+		return value
 	}
 
-	ftr, err := filter.ParseAttrExp([]byte(attrExpr.String()))
-	if err != nil {
-		return nil, err
+	values, ok := value.(map[string]any)
+	if !ok {
+		return value
 	}
 
-	index := -1
+	for name, v := range values {
+		c[findKey(c, name)] = v
+	}
 
-	switch ftr.Operator {
-	case filter.EQ:
-		for i, v := range value {
-			originalValue, ok := v.(map[string]any)
+	return c
+}
 
-			if !ok {
-				return nil, serrors.ScimErrorInvalidPath
-			}
+func replaceMatches(target *patchTarget, value any) error {
+	values, matches, err := matchValues(target)
+	if err != nil {
+		return err
+	}
 
-			value, ok := originalValue[ftr.AttributePath.AttributeName].(string)
+	if len(matches) == 0 {
+		return serrors.ScimErrorNoTarget
+	}
 
-			if ok && value == ftr.CompareValue {
-				index = i
-			}
+	for _, i := range matches {
+		if target.sub == "" {
+			values[i] = value
+			continue
 		}
 
-		if index == -1 {
-			return nil, serrors.ScimErrorMutability
+		element, ok := values[i].(map[string]any)
+		if !ok {
+			return serrors.ScimErrorInvalidPath
 		}
 
-		if originalValue, ok := value[index].(map[string]any); ok {
-			originalValue[*op.Path.SubAttribute] = op.Value
-			value[index] = originalValue
-
-			return value, nil
-		} else {
-			return nil, serrors.ScimErrorInvalidPath
-		}
-	case filter.PR, filter.NE, filter.CO, filter.SW, filter.EW, filter.GT, filter.GE, filter.LT, filter.LE:
-		return nil, serrors.ScimErrorBadRequest("operand not supported")
+		element[findKey(element, target.sub)] = value
 	}
 
-	return value, nil
+	return nil
 }
 
-func AddProperty(objectProps scim.ResourceAttributes, op scim.PatchOperation) (scim.ResourceAttributes, error) {
-	// simple add property
-	switch value := op.Value.(type) {
-	case string:
-		if objectProps[op.Path.AttributePath.AttributeName] != nil {
-			return nil, serrors.ScimErrorUniqueness
-		}
-
-		objectProps[op.Path.AttributePath.AttributeName] = op.Value
+// setSubAttribute sets the sub-attribute of a complex attribute, or of every value of a multi-valued attribute.
+func setSubAttribute(target *patchTarget, value any) error {
+	switch v := target.value().(type) {
+	case nil:
+		target.set(map[string]any{target.sub: value})
 	case map[string]any:
-		for k, v := range value {
-			if objectProps[k] != nil {
-				return nil, serrors.ScimErrorUniqueness
+		v[findKey(v, target.sub)] = value
+	case []any:
+		for _, item := range v {
+			element, ok := item.(map[string]any)
+			if !ok {
+				return serrors.ScimErrorInvalidPath
 			}
 
-			objectProps[k] = v
+			element[findKey(element, target.sub)] = value
 		}
-	case []any:
-		for _, v := range value {
-			switch val := v.(type) {
-			case string:
-				if objectProps[op.Path.AttributePath.AttributeName] == nil {
-					objectProps[op.Path.AttributePath.AttributeName] = make([]string, 0)
-				}
+	default:
+		return serrors.ScimErrorInvalidPath
+	}
 
-				if attrProps, ok := objectProps[op.Path.AttributePath.AttributeName].([]any); ok {
-					objectProps[op.Path.AttributePath.AttributeName] = append(attrProps, v)
-				} else {
-					return nil, serrors.ScimErrorInvalidPath
-				}
-			case map[string]any:
-				if objectProps[op.Path.AttributePath.AttributeName] == nil {
-					objectProps[op.Path.AttributePath.AttributeName] = make([]any, 0)
-				}
+	return nil
+}
 
-				properties := val
-				attrProps, ok := objectProps[op.Path.AttributePath.AttributeName].([]any)
+// matchValues returns the values of the target multi-valued attribute and the indexes of those matching the
+// target filter.
+func matchValues(target *patchTarget) ([]any, []int, error) {
+	var values []any
 
-				if !ok {
-					return nil, serrors.ScimErrorInvalidPath
-				}
+	switch v := target.value().(type) {
+	case nil:
+	case []any:
+		values = v
+	default:
+		return nil, nil, serrors.ScimErrorInvalidPath
+	}
 
-				objectProps[op.Path.AttributePath.AttributeName] = append(attrProps, properties)
-			}
+	var matches []int
+
+	for i, v := range values {
+		ok, err := matchFilter(v, target.filter, target.exact)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ok {
+			matches = append(matches, i)
 		}
 	}
 
-	return objectProps, nil
+	return values, matches, nil
+}
+
+// matchFilter reports whether a value of a multi-valued attribute matches a value filter. The values of simple
+// multi-valued attributes are referred to as "value" in the filter. Strings are compared case-sensitively when
+// exact reports that the attribute they belong to is case exact.
+func matchFilter(value any, expr filter.Expression, exact func(filter.AttributePath) bool) (bool, error) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		return compare(attributeValue(value, e.AttributePath), e.Operator, e.CompareValue, exact(e.AttributePath))
+	case *filter.LogicalExpression:
+		left, err := matchFilter(value, e.Left, exact)
+		if err != nil {
+			return false, err
+		}
+
+		if e.Operator == filter.AND && !left || e.Operator == filter.OR && left {
+			return left, nil
+		}
+
+		return matchFilter(value, e.Right, exact)
+	case *filter.NotExpression:
+		ok, err := matchFilter(value, e.Expression, exact)
+		return !ok, err
+	default:
+		return false, serrors.ScimErrorInvalidFilter
+	}
 }
 
-func patchOrRemoveSlice(value []any, op scim.PatchOperation, objectProps scim.ResourceAttributes) (scim.ResourceAttributes, error) {
-	attrExpr, ok := op.Path.ValueExpression.(*filter.AttributeExpression)
+func attributeValue(value any, path filter.AttributePath) any {
+	element, ok := value.(map[string]any)
 	if !ok {
-		return nil, serrors.ScimErrorInvalidPath
+		if strings.EqualFold(path.AttributeName, "value") && path.SubAttribute == nil {
+			return value
+		}
+
+		return nil
 	}
 
-	ftr, err := filter.ParseAttrExp([]byte(attrExpr.String()))
-	if err != nil {
-		return nil, err
+	v := element[findKey(element, path.AttributeName)]
+
+	if path.SubAttribute != nil {
+		complexValue, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		return complexValue[findKey(complexValue, *path.SubAttribute)]
 	}
 
-	index := -1
+	return v
+}
 
-	if ftr.Operator == filter.EQ {
-		for i, v := range value {
-			originalValue, ok := v.(map[string]any)
-			if !ok {
-				return nil, serrors.ScimErrorInvalidPath
-			}
+// compare evaluates a comparison operator. Strings are compared case-insensitively unless caseExact is set, as
+// most SCIM attributes are not case exact.
+func compare(value any, op filter.CompareOperator, compareValue any, caseExact bool) (bool, error) {
+	if op == filter.PR {
+		return present(value), nil
+	}
 
-			value, ok := originalValue[ftr.AttributePath.AttributeName].(string)
-			if ok && value == ftr.CompareValue {
-				index = i
+	if values, ok := value.([]any); ok {
+		for _, v := range values {
+			if ok, err := compare(v, op, compareValue, caseExact); err != nil || ok {
+				return ok, err
 			}
 		}
 
-		if index == -1 {
-			return nil, serrors.ScimErrorMutability
+		return false, nil
+	}
+
+	switch cv := compareValue.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return op == filter.NE, nil
+		}
+
+		if !caseExact {
+			v, cv = strings.ToLower(v), strings.ToLower(cv)
 		}
 
-		attrProps, ok := objectProps[op.Path.AttributePath.AttributeName].([]any)
+		return compareStrings(v, op, cv)
+	case bool, nil:
+		switch op {
+		case filter.EQ:
+			return value == cv, nil
+		case filter.NE:
+			return value != cv, nil
+		default:
+			return false, serrors.ScimErrorInvalidFilter
+		}
+	default:
+		c, ok := toFloat(compareValue)
+		if !ok {
+			return false, serrors.ScimErrorInvalidFilter
+		}
 
+		v, ok := toFloat(value)
 		if !ok {
-			return nil, serrors.ScimErrorInvalidPath
+			return op == filter.NE, nil
 		}
 
-		objectProps[op.Path.AttributePath.AttributeName] = append(attrProps[:index], attrProps[index+1:]...)
+		return compareNumbers(v, op, c)
+	}
+}
+
+func compareStrings(value string, op filter.CompareOperator, compareValue string) (bool, error) {
+	switch op {
+	case filter.CO:
+		return strings.Contains(value, compareValue), nil
+	case filter.SW:
+		return strings.HasPrefix(value, compareValue), nil
+	case filter.EW:
+		return strings.HasSuffix(value, compareValue), nil
+	default:
+		return ordered(strings.Compare(value, compareValue), op)
 	}
+}
 
-	return objectProps, nil
+func compareNumbers(value float64, op filter.CompareOperator, compareValue float64) (bool, error) {
+	switch {
+	case value < compareValue:
+		return ordered(-1, op)
+	case value > compareValue:
+		return ordered(1, op)
+	default:
+		return ordered(0, op)
+	}
+}
+
+func ordered(cmp int, op filter.CompareOperator) (bool, error) {
+	switch op {
+	case filter.EQ:
+		return cmp == 0, nil
+	case filter.NE:
+		return cmp != 0, nil
+	case filter.GT:
+		return cmp > 0, nil
+	case filter.GE:
+		return cmp >= 0, nil
+	case filter.LT:
+		return cmp < 0, nil
+	case filter.LE:
+		return cmp <= 0, nil
+	default:
+		return false, serrors.ScimErrorInvalidFilter
+	}
+}
+
+func present(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
 }
@@ -0,0 +1,299 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/common"
+	"github.com/elimity-com/scim"
+	"github.com/scim2/filter-parser/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func patchOp(t *testing.T, op, path string, value any) scim.PatchOperation {
+	t.Helper()
+
+	p, err := filter.ParsePath([]byte(path))
+	require.NoError(t, err)
+
+	return scim.PatchOperation{Op: op, Path: &p, Value: value}
+}
+
+func rickAttributes() scim.ResourceAttributes {
+	return scim.ResourceAttributes{
+		"userName":    "rick",
+		"displayName": "Rick",
+		"name":        map[string]any{"givenName": "Rick", "familyName": "Sanchez"},
+		"emails":      []any{map[string]any{"value": "rick@the-citadel.com", "type": "work"}},
+	}
+}
+
+func TestApplyPatchAppliesAllOperations(t *testing.T) {
+	assert := require.New(t)
+
+	attributes := rickAttributes()
+
+	result, err := common.ApplyPatch(attributes, []scim.PatchOperation{
+		patchOp(t, scim.PatchOperationReplace, "displayName", "Rick Sanchez"),
+		patchOp(t, scim.PatchOperationReplace, "name.givenName", "Richard"),
+		patchOp(t, scim.PatchOperationAdd, "emails", []any{map[string]any{"value": "rick@home", "type": "home"}}),
+	}, nil)
+	assert.NoError(err)
+
+	assert.Equal("Rick Sanchez", result["displayName"])
+	assert.Equal("Richard", result["name"].(map[string]any)["givenName"])
+	assert.Len(result["emails"], 2)
+
+	assert.Equal(rickAttributes(), attributes)
+}
+
+func TestApplyPatchIsAtomic(t *testing.T) {
+	assert := require.New(t)
+
+	attributes := rickAttributes()
+
+	_, err := common.ApplyPatch(attributes, []scim.PatchOperation{
+		patchOp(t, scim.PatchOperationReplace, "displayName", "Rick Sanchez"),
+		patchOp(t, scim.PatchOperationReplace, "name.givenName", "Richard"),
+		patchOp(t, scim.PatchOperationReplace, `emails[type eq "home"].value`, "rick@home"),
+	}, nil)
+	assert.Error(err)
+	assert.Equal(rickAttributes(), attributes)
+}
+
+func TestApplyPatchPaths(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	attributes := func() scim.ResourceAttributes {
+		attr := rickAttributes()
+		attr["active"] = true
+		attr["emails"] = []any{
+			map[string]any{"value": "rick@the-citadel.com", "type": "work", "primary": true},
+			map[string]any{"value": "rick@home.org", "type": "home"},
+		}
+		attr[enterprise] = map[string]any{"employeeNumber": "C-137", "manager": map[string]any{"value": "morty"}}
+
+		return attr
+	}
+
+	tests := []struct {
+		name   string
+		op     scim.PatchOperation
+		check  func(*require.Assertions, scim.ResourceAttributes)
+		expErr bool
+	}{
+		{
+			name: "replace with logical filter",
+			op:   patchOp(t, scim.PatchOperationReplace, `emails[type eq "home" or value ew "citadel.com"].display`, "x"),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				for _, email := range attr["emails"].([]any) {
+					assert.Equal("x", email.(map[string]any)["display"])
+				}
+			},
+		},
+		{
+			name: "remove with comparison operator",
+			op:   patchOp(t, scim.PatchOperationRemove, `emails[value sw "RICK@HOME"]`, nil),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Len(attr["emails"], 1)
+			},
+		},
+		{
+			name: "remove with presence filter",
+			op:   patchOp(t, scim.PatchOperationRemove, `emails[primary pr]`, nil),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal("home", attr["emails"].([]any)[0].(map[string]any)["type"])
+			},
+		},
+		{
+			name: "remove whole multi-valued attribute",
+			op:   patchOp(t, scim.PatchOperationRemove, "emails", nil),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.NotContains(attr, "emails")
+			},
+		},
+		{
+			name: "remove complex attribute",
+			op:   patchOp(t, scim.PatchOperationRemove, "name", nil),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.NotContains(attr, "name")
+			},
+		},
+		{
+			name: "replace complex attribute keeps unspecified sub-attributes",
+			op:   patchOp(t, scim.PatchOperationReplace, "name", map[string]any{"givenName": "Richard"}),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal(map[string]any{"givenName": "Richard", "familyName": "Sanchez"}, attr["name"])
+			},
+		},
+		{
+			name: "replace boolean with case-insensitive name",
+			op:   patchOp(t, scim.PatchOperationReplace, "ACTIVE", false),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal(false, attr["active"])
+			},
+		},
+		{
+			name: "replace extension sub-attribute",
+			op:   patchOp(t, scim.PatchOperationReplace, enterprise+":manager.value", "summer"),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal("summer", attr[enterprise].(map[string]any)["manager"].(map[string]any)["value"])
+			},
+		},
+		{
+			name: "remove extension attribute",
+			op:   patchOp(t, scim.PatchOperationRemove, enterprise+":employeeNumber", nil),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.NotContains(attr[enterprise], "employeeNumber")
+			},
+		},
+		{
+			name: "add to missing value creates it",
+			op:   patchOp(t, scim.PatchOperationAdd, `emails[type eq "other"].value`, "rick@other.org"),
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Contains(attr["emails"], map[string]any{"type": "other", "value": "rick@other.org"})
+			},
+		},
+		{
+			name: "add without path merges",
+			op: scim.PatchOperation{Op: scim.PatchOperationAdd, Value: map[string]any{
+				"name":     map[string]any{"middleName": "C"},
+				enterprise: map[string]any{"department": "science"},
+			}},
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal(map[string]any{"givenName": "Rick", "familyName": "Sanchez", "middleName": "C"}, attr["name"])
+				assert.Equal("C-137", attr[enterprise].(map[string]any)["employeeNumber"])
+				assert.Equal("science", attr[enterprise].(map[string]any)["department"])
+			},
+		},
+		{
+			name: "add without path with extension attribute URN",
+			op: scim.PatchOperation{Op: scim.PatchOperationAdd, Value: map[string]any{
+				enterprise + ":manager": map[string]any{"value": "summer"},
+			}},
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal(map[string]any{"value": "summer"}, attr[enterprise].(map[string]any)["manager"])
+				assert.Equal("C-137", attr[enterprise].(map[string]any)["employeeNumber"])
+				assert.NotContains(attr, enterprise+":manager")
+			},
+		},
+		{
+			name: "replace without path with extension sub-attribute URN",
+			op: scim.PatchOperation{Op: scim.PatchOperationReplace, Value: map[string]any{
+				enterprise + ":manager.value": "summer",
+				"displayName":                 "Rick Sanchez",
+			}},
+			check: func(assert *require.Assertions, attr scim.ResourceAttributes) {
+				assert.Equal(map[string]any{"value": "summer"}, attr[enterprise].(map[string]any)["manager"])
+				assert.Equal("Rick Sanchez", attr["displayName"])
+			},
+		},
+		{
+			name:   "replace with unmatched filter",
+			op:     patchOp(t, scim.PatchOperationReplace, `emails[type eq "other"].value`, "x"),
+			expErr: true,
+		},
+		{
+			name:   "remove without path",
+			op:     scim.PatchOperation{Op: scim.PatchOperationRemove},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			result, err := common.ApplyPatch(attributes(), []scim.PatchOperation{tc.op}, nil)
+			if tc.expErr {
+				assert.Error(err)
+				return
+			}
+
+			assert.NoError(err)
+			tc.check(assert, result)
+		})
+	}
+}
+
+func TestApplyPatchExtensionURNs(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	tests := []struct {
+		name string
+		op   scim.PatchOperation
+	}{
+		{
+			name: "add extension object",
+			op:   patchOp(t, scim.PatchOperationAdd, enterprise, map[string]any{"employeeNumber": "C-137"}),
+		},
+		{
+			name: "add extension attribute",
+			op:   patchOp(t, scim.PatchOperationAdd, enterprise+":employeeNumber", "C-137"),
+		},
+		{
+			name: "add extension object without path",
+			op: scim.PatchOperation{Op: scim.PatchOperationAdd, Value: map[string]any{
+				enterprise: map[string]any{"employeeNumber": "C-137"},
+			}},
+		},
+		{
+			name: "replace extension attribute without path",
+			op: scim.PatchOperation{Op: scim.PatchOperationReplace, Value: map[string]any{
+				enterprise + ":employeeNumber": "C-137",
+			}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			result, err := common.ApplyPatch(rickAttributes(), []scim.PatchOperation{tc.op}, nil)
+			assert.NoError(err)
+
+			assert.Equal(map[string]any{"employeeNumber": "C-137"}, result[enterprise])
+			assert.Len(result, len(rickAttributes())+1)
+		})
+	}
+}
+
+func TestApplyPatchCaseExact(t *testing.T) {
+	attributes := scim.ResourceAttributes{
+		"displayName": "Council of Ricks",
+		"members":     []any{map[string]any{"value": "rick", "display": "Rick"}},
+	}
+
+	caseExact := func(path string) bool { return path == "members.value" }
+
+	tests := []struct {
+		name    string
+		op      scim.PatchOperation
+		members int
+	}{
+		{
+			name:    "case exact attribute with other case",
+			op:      patchOp(t, scim.PatchOperationRemove, `members[value eq "Rick"]`, nil),
+			members: 1,
+		},
+		{
+			name: "case exact attribute with same case",
+			op:   patchOp(t, scim.PatchOperationRemove, `members[value eq "rick"]`, nil),
+		},
+		{
+			name: "other attribute with other case",
+			op:   patchOp(t, scim.PatchOperationRemove, `members[display eq "RICK"]`, nil),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			result, err := common.ApplyPatch(attributes, []scim.PatchOperation{tc.op}, caseExact)
+			assert.NoError(err)
+
+			members, _ := result["members"].([]any)
+			assert.Len(members, tc.members)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aserto-dev/scim/common/audit"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Audited operations.
+const (
+	auditCreate  = "create"
+	auditReplace = "replace"
+	auditPatch   = "patch"
+	auditDelete  = "delete"
+)
+
+// fileSink writes audit entries as JSON lines to a file that is rotated when it reaches its maximum size.
+type fileSink struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+}
+
+func newFileSink(cfg *config.AuditFileConfig) *fileSink {
+	return &fileSink{out: &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}}
+}
+
+func (s *fileSink) Write(_ context.Context, entry *audit.Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.out.Write(append(line, '\n'))
+
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.out.Close()
+}
+
+// logSink writes audit entries to the log.
+type logSink struct {
+	log *zerolog.Logger
+}
+
+func (s logSink) Write(ctx context.Context, entry *audit.Entry) error {
+	s.log.Info().Ctx(ctx).Interface("audit", entry).Msg("audit")
+	return nil
+}
+
+// newAuditSink returns the sink configured to store audit entries.
+func newAuditSink(cfg *config.AuditConfig, log *zerolog.Logger) (audit.Sink, error) {
+	switch cfg.Sink {
+	case config.AuditSinkFile:
+		return newFileSink(&cfg.File), nil
+	case config.AuditSinkLog:
+		auditLogger := log.With().Str("component", "audit").Logger()
+		return logSink{log: &auditLogger}, nil
+	default:
+		return nil, errors.Errorf("unknown audit sink [%s]", cfg.Sink)
+	}
+}
+
+// auditedHandler records an audit entry of each operation that changes a resource. Entries hold the attribute
+// changes of the resource and the directory writes made by the operation, including those of failed operations.
+type auditedHandler struct {
+	handler      handlers.ResourceHandler
+	sink         audit.Sink
+	redactor     *audit.Redactor
+	tenant       string
+	resourceType string
+	log          *zerolog.Logger
+}
+
+func (h auditedHandler) Create(ctx context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	recCtx, rec := audit.WithRecorder(ctx)
+	resource, err := h.handler.Create(recCtx, attributes)
+
+	h.record(ctx, rec, auditCreate, resource.ID, nil, resource.Attributes, err)
+
+	return resource, err
+}
+
+func (h auditedHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
+	return h.handler.Get(ctx, id)
+}
+
+func (h auditedHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
+	return h.handler.GetAll(ctx, params)
+}
+
+func (h auditedHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	before := h.before(ctx, id)
+
+	recCtx, rec := audit.WithRecorder(ctx)
+	resource, err := h.handler.Patch(recCtx, id, operations)
+
+	h.record(ctx, rec, auditPatch, id, before, resource.Attributes, err)
+
+	return resource, err
+}
+
+func (h auditedHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	before := h.before(ctx, id)
+
+	recCtx, rec := audit.WithRecorder(ctx)
+	resource, err := h.handler.Replace(recCtx, id, attributes)
+
+	h.record(ctx, rec, auditReplace, id, before, resource.Attributes, err)
+
+	return resource, err
+}
+
+func (h auditedHandler) Delete(ctx context.Context, id string) error {
+	before := h.before(ctx, id)
+
+	recCtx, rec := audit.WithRecorder(ctx)
+	err := h.handler.Delete(recCtx, id)
+
+	h.record(ctx, rec, auditDelete, id, before, nil, err)
+
+	return err
+}
+
+// before returns the attributes of a resource before it is changed, or nil if it can't be read.
+func (h auditedHandler) before(ctx context.Context, id string) scim.ResourceAttributes {
+	resource, err := h.handler.Get(ctx, id)
+	if err != nil {
+		return nil
+	}
+
+	return resource.Attributes
+}
+
+func (h auditedHandler) record(
+	ctx context.Context,
+	rec *audit.Recorder,
+	operation, id string,
+	before, after scim.ResourceAttributes,
+	opErr error,
+) {
+	entry := &audit.Entry{
+		Time:         time.Now().UTC(),
+		Tenant:       h.tenant,
+		Caller:       handlers.Caller(ctx),
+		Operation:    operation,
+		ResourceType: h.resourceType,
+		ResourceID:   id,
+		Changes:      attributeChanges(before, after),
+		Writes:       rec.Writes(),
+		RolledBack:   rec.RolledBack(),
+	}
+
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+
+	h.redactor.Entry(entry)
+
+	if err := h.sink.Write(ctx, entry); err != nil {
+		h.log.Err(err).Str("operation", operation).Str("id", id).Msg("failed to write audit entry")
+	}
+}
+
+// attributeChanges returns the top-level attributes whose values differ between before and after.
+func attributeChanges(before, after scim.ResourceAttributes) map[string]*audit.Change {
+	changes := map[string]*audit.Change{}
+
+	for name, value := range before {
+		if afterValue, ok := after[name]; !ok || !reflect.DeepEqual(value, afterValue) {
+			changes[name] = &audit.Change{Before: value, After: afterValue}
+		}
+	}
+
+	for name, value := range after {
+		if _, ok := before[name]; !ok {
+			changes[name] = &audit.Change{After: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return changes
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dsc "github.com/aserto-dev/go-directory/aserto/directory/common/v3"
+	"github.com/aserto-dev/scim/common/audit"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type memorySink struct {
+	entries []*audit.Entry
+}
+
+func (s *memorySink) Write(_ context.Context, entry *audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// userHandler stores the attributes of a single user and records the directory writes of its changes.
+type userHandler struct {
+	nopHandler
+	attributes scim.ResourceAttributes
+}
+
+func (h *userHandler) Get(_ context.Context, id string) (scim.Resource, error) {
+	return scim.Resource{ID: id, Attributes: h.attributes}, nil
+}
+
+func (h *userHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	properties, err := structpb.NewStruct(map[string]any{"password": "s3cret", "department": attributes["department"]})
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	audit.RecordObject(ctx, audit.ActionSetObject, &dsc.Object{Type: "user", Id: id, Properties: properties})
+	audit.RecordRelation(ctx, audit.ActionDeleteRelation, &dsc.Relation{
+		ObjectType: "group", ObjectId: "admins", Relation: "member", SubjectType: "user", SubjectId: id,
+	})
+
+	h.attributes = attributes
+
+	return scim.Resource{ID: id, Attributes: attributes}, nil
+}
+
+func (h *userHandler) Delete(ctx context.Context, id string) error {
+	audit.RecordObject(ctx, audit.ActionDeleteObject, &dsc.Object{Type: "user", Id: id})
+	audit.RecordRollback(ctx)
+
+	return errors.New("directory unavailable")
+}
+
+func TestAuditedHandler(t *testing.T) {
+	assert := require.New(t)
+
+	logger := zerolog.Nop()
+	sink := &memorySink{}
+	handler := auditedHandler{
+		handler: &userHandler{attributes: scim.ResourceAttributes{
+			"userName":   "rick",
+			"password":   "old",
+			"department": "sales",
+			"active":     true,
+		}},
+		sink:         sink,
+		redactor:     audit.NewRedactor("department"),
+		tenant:       "acme",
+		resourceType: "User",
+		log:          &logger,
+	}
+
+	ctx := handlers.WithCaller(context.Background(), "okta")
+
+	_, err := handler.Replace(ctx, "rick", scim.ResourceAttributes{
+		"userName":   "rick",
+		"password":   "new",
+		"department": "engineering",
+		"title":      "engineer",
+	})
+	assert.NoError(err)
+	assert.Error(handler.Delete(ctx, "rick"))
+	assert.Len(sink.entries, 2)
+
+	replaced := sink.entries[0]
+	assert.Equal("acme", replaced.Tenant)
+	assert.Equal("okta", replaced.Caller)
+	assert.Equal(auditReplace, replaced.Operation)
+	assert.Equal("User", replaced.ResourceType)
+	assert.Equal("rick", replaced.ResourceID)
+	assert.Empty(replaced.Error)
+	assert.Equal(map[string]*audit.Change{
+		"password":   {Before: audit.RedactedValue, After: audit.RedactedValue},
+		"department": {Before: audit.RedactedValue, After: audit.RedactedValue},
+		"active":     {Before: true},
+		"title":      {After: "engineer"},
+	}, replaced.Changes)
+
+	assert.Len(replaced.Writes, 2)
+	assert.Equal(audit.ActionSetObject, replaced.Writes[0].Action)
+	assert.Equal(map[string]any{"password": audit.RedactedValue, "department": audit.RedactedValue},
+		replaced.Writes[0].Object.Properties)
+	assert.Equal(audit.ActionDeleteRelation, replaced.Writes[1].Action)
+	assert.Equal("admins", replaced.Writes[1].Relation.ObjectID)
+
+	deleted := sink.entries[1]
+	assert.Equal(auditDelete, deleted.Operation)
+	assert.Equal("directory unavailable", deleted.Error)
+	assert.True(deleted.RolledBack)
+	assert.Len(deleted.Writes, 1)
+	assert.Equal(&audit.Change{Before: "rick"}, deleted.Changes["userName"])
+}
+
+func TestFileSink(t *testing.T) {
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink := newFileSink(&config.AuditFileConfig{Path: path, MaxSizeMB: 1})
+
+	assert.NoError(sink.Write(context.Background(), &audit.Entry{Operation: auditCreate, ResourceType: "User"}))
+	assert.NoError(sink.Write(context.Background(), &audit.Entry{Operation: auditDelete, ResourceType: "Group"}))
+	assert.NoError(sink.Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	assert.Len(lines, 2)
+
+	var entry audit.Entry
+	assert.NoError(json.Unmarshal(lines[1], &entry))
+	assert.Equal(auditDelete, entry.Operation)
+	assert.Equal("Group", entry.ResourceType)
+}
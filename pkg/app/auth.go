@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type application struct {
+	cfg           *config.AuthConfig
+	jwt           *jwtValidator
+	log           *zerolog.Logger
+	resourceTypes []scim.ResourceType
+}
+
+// caller is the authenticated identity of a request. Callers authenticated with a named credential are
+// restricted to its permissions, other callers can perform every operation.
+type caller struct {
+	name        string
+	permissions []*config.Permission
+	restricted  bool
+}
+
+type callerKey struct{}
+
+func withCaller(ctx context.Context, c *caller) context.Context {
+	return handlers.WithCaller(context.WithValue(ctx, callerKey{}, c), c.name)
+}
+
+// callerFrom returns the caller of a request, or nil if authentication is disabled.
+func callerFrom(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+	return c
+}
+
+// allowed reports whether the caller may perform an operation on a resource type.
+func (c *caller) allowed(resourceType, operation string) bool {
+	if c == nil || !c.restricted {
+		return true
+	}
+
+	return slices.ContainsFunc(c.permissions, func(p *config.Permission) bool {
+		return (slices.Contains(p.ResourceTypes, config.OperationAll) ||
+			slices.ContainsFunc(p.ResourceTypes, func(name string) bool { return strings.EqualFold(name, resourceType) })) &&
+			(slices.Contains(p.Operations, config.OperationAll) || slices.Contains(p.Operations, operation))
+	})
+}
+
+func (app *application) auth(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.cfg.Basic.Enabled && !app.cfg.Bearer.Enabled && !app.cfg.JWT.Enabled && !app.cfg.MTLS.Enabled &&
+			len(app.cfg.Credentials) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c := app.authenticate(r)
+		if c == nil {
+			if app.cfg.Basic.Enabled || slices.ContainsFunc(app.cfg.Credentials, isBasicCredential) {
+				w.Header().Add("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+			}
+
+			if app.cfg.Bearer.Enabled || app.cfg.JWT.Enabled || slices.ContainsFunc(app.cfg.Credentials, isTokenCredential) {
+				w.Header().Add("WWW-Authenticate", `Bearer realm="restricted"`)
+			}
+
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		logger := app.log.With().Ctx(r.Context()).Str("caller", c.name).Str("method", r.Method).Str("path", r.URL.Path).Logger()
+
+		if resourceType, operation, ok := app.operation(r); ok && !c.allowed(resourceType, operation) {
+			logger.Warn().Msg("operation not permitted")
+			writeScimError(w, forbidden(operation, resourceType))
+
+			return
+		}
+
+		logger.Info().Msg("authenticated request")
+
+		next.ServeHTTP(w, r.WithContext(withCaller(r.Context(), c)))
+	})
+}
+
+// authenticate returns the caller identified by the client certificate, basic credentials or bearer token of
+// a request, or nil if the request isn't authenticated.
+func (app *application) authenticate(r *http.Request) *caller {
+	if app.cfg.MTLS.Enabled && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		certificate := r.TLS.VerifiedChains[0][0]
+
+		for _, credential := range app.cfg.Credentials {
+			if credential.CertificateSubject != "" && certificateMatches(certificate, credential.CertificateSubject) {
+				return credentialCaller(credential)
+			}
+		}
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		if app.cfg.Basic.Enabled && app.checkBasicAuth(username, password) {
+			return &caller{name: username}
+		}
+
+		for _, credential := range app.cfg.Credentials {
+			if isBasicCredential(credential) &&
+				subtle.ConstantTimeCompare([]byte(credential.Username), []byte(username)) == 1 &&
+				bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(password)) == nil {
+				return credentialCaller(credential)
+			}
+		}
+
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+
+	if app.cfg.Bearer.Enabled && subtle.ConstantTimeCompare([]byte(app.cfg.Bearer.Token), []byte(token)) == 1 {
+		return &caller{name: "bearer"}
+	}
+
+	tokenHash := sha256.Sum256([]byte(token))
+
+	for _, credential := range app.cfg.Credentials {
+		expected, err := hex.DecodeString(credential.TokenHash)
+		if err == nil && subtle.ConstantTimeCompare(tokenHash[:], expected) == 1 {
+			return credentialCaller(credential)
+		}
+	}
+
+	if app.jwt == nil {
+		return nil
+	}
+
+	jwtToken, err := app.jwt.Validate(token)
+	if err != nil {
+		app.log.Debug().Err(err).Msg("invalid bearer token")
+		return nil
+	}
+
+	return &caller{name: jwtToken.Subject()}
+}
+
+func (app *application) checkBasicAuth(username, password string) bool {
+	if username == "" || password == "" {
+		return false
+	}
+
+	usernameHash := sha256.Sum256([]byte(username))
+	passwordHash := sha256.Sum256([]byte(password))
+
+	expectedUsernameHash := sha256.Sum256([]byte(app.cfg.Basic.Username))
+	expectedPasswordHash := sha256.Sum256([]byte(app.cfg.Basic.Password))
+
+	usernameMatch := (subtle.ConstantTimeCompare(usernameHash[:], expectedUsernameHash[:]) == 1)
+	passwordMatch := (subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash[:]) == 1)
+
+	return usernameMatch && passwordMatch
+}
+
+// operation returns the resource type and the operation addressed by a request. Discovery and bulk requests
+// address no single resource type, the operations of bulk requests are authorized one at a time.
+func (app *application) operation(r *http.Request) (string, string, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/v2")
+	endpoint, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+
+	for _, resourceType := range app.resourceTypes {
+		if !strings.EqualFold(resourceType.Endpoint, "/"+endpoint) {
+			continue
+		}
+
+		if r.Method == http.MethodPost && strings.HasSuffix(path, "/.search") {
+			return resourceType.Name, config.OperationRead, true
+		}
+
+		return resourceType.Name, methodOperation(r.Method), true
+	}
+
+	return "", "", false
+}
+
+func methodOperation(method string) string {
+	switch method {
+	case http.MethodPost:
+		return config.OperationCreate
+	case http.MethodPut, http.MethodPatch:
+		return config.OperationUpdate
+	case http.MethodDelete:
+		return config.OperationDelete
+	default:
+		return config.OperationRead
+	}
+}
+
+// certificateMatches reports whether the common name or one of the subject alternative names of a certificate
+// is the given subject.
+func certificateMatches(certificate *x509.Certificate, subject string) bool {
+	names := slices.Concat([]string{certificate.Subject.CommonName}, certificate.DNSNames, certificate.EmailAddresses)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+
+	return slices.Contains(names, subject)
+}
+
+// withClientCAs configures a server to verify the client certificates that callers present against the CA bundle
+// in the given file. Client certificates are optional, so that callers can use the other authentication schemes.
+func withClientCAs(tlsConfig *tls.Config, caCertPath string) error {
+	pem, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read client ca bundle '%s'", caCertPath)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.Errorf("no certificates found in client ca bundle '%s'", caCertPath)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return nil
+}
+
+func credentialCaller(credential *config.Credential) *caller {
+	return &caller{name: credential.Name, permissions: credential.Permissions, restricted: true}
+}
+
+func isBasicCredential(credential *config.Credential) bool {
+	return credential.Username != ""
+}
+
+func isTokenCredential(credential *config.Credential) bool {
+	return credential.TokenHash != ""
+}
+
+func forbidden(operation, resourceType string) serrors.ScimError {
+	return serrors.ScimError{
+		Status: http.StatusForbidden,
+		Detail: fmt.Sprintf("The caller is not permitted to %s %s resources.", operation, resourceType),
+	}
+}
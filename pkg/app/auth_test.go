@@ -0,0 +1,144 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthCredentials(t *testing.T) {
+	assert := require.New(t)
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("hr-secret"), bcrypt.MinCost)
+	assert.NoError(err)
+
+	tokenHash := sha256.Sum256([]byte("okta-token"))
+
+	logger := zerolog.Nop()
+	app := &application{
+		cfg: &config.AuthConfig{
+			Credentials: []*config.Credential{
+				{
+					Name:         "hr-tool",
+					Username:     "hr",
+					PasswordHash: string(passwordHash),
+					Permissions:  []*config.Permission{{ResourceTypes: []string{"User"}, Operations: []string{"read"}}},
+				},
+				{
+					Name:        "okta",
+					TokenHash:   hex.EncodeToString(tokenHash[:]),
+					Permissions: []*config.Permission{{ResourceTypes: []string{"*"}, Operations: []string{"*"}}},
+				},
+			},
+		},
+		log:           &logger,
+		resourceTypes: []scim.ResourceType{{Name: "User", Endpoint: "/Users"}, {Name: "Group", Endpoint: "/Groups"}},
+	}
+
+	var callerName string
+
+	handler := app.auth(func(w http.ResponseWriter, r *http.Request) {
+		callerName = handlers.Caller(r.Context())
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(r *http.Request)
+		status int
+		caller string
+	}{
+		{
+			name: "read users", method: http.MethodGet, path: "/Users",
+			auth: func(r *http.Request) { r.SetBasicAuth("hr", "hr-secret") }, status: http.StatusOK, caller: "hr-tool",
+		},
+		{
+			name: "create user", method: http.MethodPost, path: "/Users",
+			auth: func(r *http.Request) { r.SetBasicAuth("hr", "hr-secret") }, status: http.StatusForbidden,
+		},
+		{
+			name: "read groups", method: http.MethodGet, path: "/v2/Groups/admins",
+			auth: func(r *http.Request) { r.SetBasicAuth("hr", "hr-secret") }, status: http.StatusForbidden,
+		},
+		{
+			name: "read schemas", method: http.MethodGet, path: "/Schemas",
+			auth: func(r *http.Request) { r.SetBasicAuth("hr", "hr-secret") }, status: http.StatusOK, caller: "hr-tool",
+		},
+		{
+			name: "wrong password", method: http.MethodGet, path: "/Users",
+			auth: func(r *http.Request) { r.SetBasicAuth("hr", "okta-token") }, status: http.StatusUnauthorized,
+		},
+		{
+			name: "delete group", method: http.MethodDelete, path: "/Groups/admins",
+			auth: func(r *http.Request) { r.Header.Set("Authorization", "Bearer okta-token") }, status: http.StatusOK, caller: "okta",
+		},
+		{
+			name: "wrong token", method: http.MethodDelete, path: "/Groups/admins",
+			auth: func(r *http.Request) { r.Header.Set("Authorization", "Bearer hr-secret") }, status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			callerName = ""
+
+			req := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			tc.auth(req)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, tc.caller, callerName)
+		})
+	}
+}
+
+func TestAuthClientCertificate(t *testing.T) {
+	assert := require.New(t)
+
+	logger := zerolog.Nop()
+	app := &application{
+		cfg: &config.AuthConfig{
+			MTLS: config.MTLSConfig{Enabled: true},
+			Credentials: []*config.Credential{
+				{
+					Name:               "okta",
+					CertificateSubject: "scim.okta.example.com",
+					Permissions:        []*config.Permission{{ResourceTypes: []string{"User"}, Operations: []string{"*"}}},
+				},
+			},
+		},
+		log:           &logger,
+		resourceTypes: []scim.ResourceType{{Name: "User", Endpoint: "/Users"}},
+	}
+
+	handler := app.auth(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(certificate *x509.Certificate) int {
+		req := httptest.NewRequest(http.MethodPost, "/Users", http.NoBody)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(http.StatusOK, request(&x509.Certificate{DNSNames: []string{"scim.okta.example.com"}}))
+	assert.Equal(http.StatusOK, request(&x509.Certificate{Subject: pkix.Name{CommonName: "scim.okta.example.com"}}))
+	assert.Equal(http.StatusUnauthorized, request(&x509.Certificate{DNSNames: []string{"hr.example.com"}}))
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+type checker interface {
+	Check(ctx context.Context, in *dsr.CheckRequest, opts ...grpc.CallOption) (*dsr.CheckResponse, error)
+	Checks(ctx context.Context, in *dsr.ChecksRequest, opts ...grpc.CallOption) (*dsr.ChecksResponse, error)
+}
+
+// checksBatchSize is the number of objects checked per directory request when filtering a list of resources.
+const checksBatchSize = 100
+
+// authorizer checks with the directory whether the caller of a request may perform an operation on an object.
+type authorizer struct {
+	cfg     *config.AuthzConfig
+	checker checker
+	logger  *zerolog.Logger
+}
+
+func newAuthorizer(cfg *config.AuthzConfig, checker checker, logger *zerolog.Logger) *authorizer {
+	authzLogger := logger.With().Str("component", "authorizer").Logger()
+
+	return &authorizer{
+		cfg:     cfg,
+		checker: checker,
+		logger:  &authzLogger,
+	}
+}
+
+// check returns a SCIM 403 error if the caller doesn't have the permission of the operation on the object.
+func (a *authorizer) check(ctx context.Context, operation, objectType, objectID string) error {
+	caller := handlers.Caller(ctx)
+	permission := a.cfg.Permission(operation)
+
+	logger := a.logger.With().Str("caller", caller).Str("permission", permission).
+		Str("object_type", objectType).Str("object_id", objectID).Logger()
+
+	if caller == "" {
+		logger.Warn().Msg("unauthenticated request denied")
+		return authzDenied(operation, objectType, objectID)
+	}
+
+	resp, err := a.checker.Check(ctx, &dsr.CheckRequest{
+		ObjectType:  objectType,
+		ObjectId:    objectID,
+		Relation:    permission,
+		SubjectType: a.cfg.SubjectType,
+		SubjectId:   caller,
+	})
+	if err != nil {
+		logger.Err(err).Msg("failed to check permission")
+		return err
+	}
+
+	if !resp.GetCheck() {
+		logger.Warn().Msg("operation denied")
+		return authzDenied(operation, objectType, objectID)
+	}
+
+	logger.Trace().Msg("operation allowed")
+
+	return nil
+}
+
+// allowed returns the ids of the objects on which the caller has the permission of the operation.
+func (a *authorizer) allowed(ctx context.Context, operation, objectType string, objectIDs []string) (map[string]bool, error) {
+	caller := handlers.Caller(ctx)
+	permission := a.cfg.Permission(operation)
+	result := make(map[string]bool, len(objectIDs))
+
+	if caller == "" {
+		return result, nil
+	}
+
+	for batch := range slices.Chunk(objectIDs, checksBatchSize) {
+		req := &dsr.ChecksRequest{
+			Default: &dsr.CheckRequest{
+				ObjectType:  objectType,
+				Relation:    permission,
+				SubjectType: a.cfg.SubjectType,
+				SubjectId:   caller,
+			},
+			Checks: make([]*dsr.CheckRequest, 0, len(batch)),
+		}
+
+		for _, id := range batch {
+			req.Checks = append(req.Checks, &dsr.CheckRequest{ObjectId: id})
+		}
+
+		resp, err := a.checker.Checks(ctx, req)
+		if err != nil {
+			a.logger.Err(err).Str("caller", caller).Str("permission", permission).Msg("failed to check permissions")
+			return nil, err
+		}
+
+		for i, check := range resp.GetChecks() {
+			if i < len(batch) && check.GetCheck() {
+				result[batch[i]] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func authzDenied(operation, objectType, objectID string) serrors.ScimError {
+	return serrors.ScimError{
+		Status: http.StatusForbidden,
+		Detail: fmt.Sprintf("The caller is not permitted to %s %s:%s.", operation, objectType, objectID),
+	}
+}
+
+// authorizedHandler authorizes each operation with the directory before passing it to a resource handler.
+// Operations on a resource are checked against its directory object, creating and listing resources against
+// the object of the resource type. Lists only hold the resources that the caller may read.
+type authorizedHandler struct {
+	handler      handlers.ResourceHandler
+	authorizer   *authorizer
+	resourceType string
+	objectType   string
+}
+
+func (h authorizedHandler) Create(ctx context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	if err := h.checkResourceType(ctx, config.OperationCreate); err != nil {
+		return scim.Resource{}, err
+	}
+
+	return h.handler.Create(ctx, attributes)
+}
+
+func (h authorizedHandler) Get(ctx context.Context, id string) (scim.Resource, error) {
+	if err := h.authorizer.check(ctx, config.OperationRead, h.objectType, id); err != nil {
+		return scim.Resource{}, err
+	}
+
+	return h.handler.Get(ctx, id)
+}
+
+func (h authorizedHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
+	if err := h.checkResourceType(ctx, config.OperationRead); err != nil {
+		return scim.Page{}, err
+	}
+
+	// The handler leaves out the resources that the caller can't read while it builds the page, so that the
+	// total and the window don't count them.
+	readable := func(ctx context.Context, ids []string) (map[string]bool, error) {
+		return h.authorizer.allowed(ctx, config.OperationRead, h.objectType, ids)
+	}
+
+	return h.handler.GetAll(handlers.WithReadable(ctx, readable), params)
+}
+
+func (h authorizedHandler) Patch(ctx context.Context, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	if err := h.authorizer.check(ctx, config.OperationUpdate, h.objectType, id); err != nil {
+		return scim.Resource{}, err
+	}
+
+	return h.handler.Patch(ctx, id, operations)
+}
+
+func (h authorizedHandler) Replace(ctx context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	if err := h.authorizer.check(ctx, config.OperationUpdate, h.objectType, id); err != nil {
+		return scim.Resource{}, err
+	}
+
+	return h.handler.Replace(ctx, id, attributes)
+}
+
+func (h authorizedHandler) Delete(ctx context.Context, id string) error {
+	if err := h.authorizer.check(ctx, config.OperationDelete, h.objectType, id); err != nil {
+		return err
+	}
+
+	return h.handler.Delete(ctx, id)
+}
+
+func (h authorizedHandler) checkResourceType(ctx context.Context, operation string) error {
+	return h.authorizer.check(ctx, operation, h.authorizer.cfg.ResourceTypeObjectType, h.resourceType)
+}
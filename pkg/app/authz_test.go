@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	dsr "github.com/aserto-dev/go-directory/aserto/directory/reader/v3"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeChecker allows the checks listed as "object_type:object_id#permission@subject_id".
+type fakeChecker map[string]bool
+
+func (c fakeChecker) Check(_ context.Context, in *dsr.CheckRequest, _ ...grpc.CallOption) (*dsr.CheckResponse, error) {
+	key := in.GetObjectType() + ":" + in.GetObjectId() + "#" + in.GetRelation() + "@" + in.GetSubjectId()
+	return &dsr.CheckResponse{Check: c[key]}, nil
+}
+
+func (c fakeChecker) Checks(ctx context.Context, in *dsr.ChecksRequest, _ ...grpc.CallOption) (*dsr.ChecksResponse, error) {
+	resp := &dsr.ChecksResponse{}
+
+	for _, check := range in.GetChecks() {
+		req := &dsr.CheckRequest{
+			ObjectType:  in.GetDefault().GetObjectType(),
+			ObjectId:    check.GetObjectId(),
+			Relation:    in.GetDefault().GetRelation(),
+			SubjectType: in.GetDefault().GetSubjectType(),
+			SubjectId:   in.GetDefault().GetSubjectId(),
+		}
+
+		result, _ := c.Check(ctx, req)
+		resp.Checks = append(resp.Checks, result)
+	}
+
+	return resp, nil
+}
+
+// listHandler lists resources with the given ids.
+type listHandler struct {
+	nopHandler
+	ids []string
+}
+
+func (h listHandler) GetAll(ctx context.Context, params scim.ListRequestParams) (scim.Page, error) {
+	page := handlers.NewPageBuilder(ctx, params)
+
+	for _, id := range h.ids {
+		if err := page.Add(scim.Resource{ID: id}); errors.Is(err, handlers.ErrPageComplete) {
+			break
+		} else if err != nil {
+			return scim.Page{}, err
+		}
+	}
+
+	return page.Page()
+}
+
+// countingChecker counts the objects checked in batches.
+type countingChecker struct {
+	fakeChecker
+	checked int
+}
+
+func (c *countingChecker) Checks(ctx context.Context, in *dsr.ChecksRequest, opts ...grpc.CallOption) (*dsr.ChecksResponse, error) {
+	c.checked += len(in.GetChecks())
+	return c.fakeChecker.Checks(ctx, in, opts...)
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Create(_ context.Context, _ scim.ResourceAttributes) (scim.Resource, error) {
+	return scim.Resource{}, nil
+}
+
+func (nopHandler) Get(_ context.Context, id string) (scim.Resource, error) {
+	return scim.Resource{ID: id}, nil
+}
+
+func (nopHandler) GetAll(_ context.Context, _ scim.ListRequestParams) (scim.Page, error) {
+	return scim.Page{}, nil
+}
+
+func (nopHandler) Patch(_ context.Context, id string, _ []scim.PatchOperation) (scim.Resource, error) {
+	return scim.Resource{ID: id}, nil
+}
+
+func (nopHandler) Replace(_ context.Context, id string, _ scim.ResourceAttributes) (scim.Resource, error) {
+	return scim.Resource{ID: id}, nil
+}
+
+func (nopHandler) Delete(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestAuthorizedHandler(t *testing.T) {
+	assert := require.New(t)
+
+	logger := zerolog.Nop()
+	cfg := &config.AuthzConfig{
+		Enabled:                true,
+		SubjectType:            "scim_client",
+		ResourceTypeObjectType: "scim_resource_type",
+		Permissions:            map[string]string{config.OperationUpdate: "can_manage"},
+	}
+
+	handler := authorizedHandler{
+		handler: nopHandler{},
+		authorizer: newAuthorizer(cfg, fakeChecker{
+			"scim_resource_type:Group#can_create@emea-idp": true,
+			"group:emea-admins#can_manage@emea-idp":        true,
+		}, &logger),
+		resourceType: "Group",
+		objectType:   "group",
+	}
+
+	ctx := handlers.WithCaller(context.Background(), "emea-idp")
+
+	_, err := handler.Create(ctx, scim.ResourceAttributes{"displayName": "emea-admins"})
+	assert.NoError(err)
+
+	_, err = handler.Patch(ctx, "emea-admins", nil)
+	assert.NoError(err)
+
+	_, err = handler.Patch(ctx, "us-admins", nil)
+	assertForbidden(t, err)
+
+	assertForbidden(t, handler.Delete(ctx, "emea-admins"))
+
+	_, err = handler.GetAll(ctx, scim.ListRequestParams{})
+	assertForbidden(t, err)
+
+	_, err = handler.Get(context.Background(), "emea-admins")
+	assertForbidden(t, err)
+}
+
+func assertForbidden(t *testing.T, err error) {
+	t.Helper()
+
+	var scimErr serrors.ScimError
+
+	require.ErrorAs(t, err, &scimErr)
+	require.Equal(t, http.StatusForbidden, scimErr.Status)
+}
+
+func TestAuthorizedHandlerGetAll(t *testing.T) {
+	assert := require.New(t)
+
+	logger := zerolog.Nop()
+	cfg := &config.AuthzConfig{
+		Enabled:                true,
+		SubjectType:            "scim_client",
+		ResourceTypeObjectType: "scim_resource_type",
+	}
+
+	handler := authorizedHandler{
+		handler: listHandler{ids: []string{"emea-admins", "us-admins", "emea-users", "emea-guests"}},
+		authorizer: newAuthorizer(cfg, fakeChecker{
+			"scim_resource_type:Group#can_read@emea-idp": true,
+			"group:emea-admins#can_read@emea-idp":        true,
+			"group:emea-users#can_read@emea-idp":         true,
+			"group:emea-guests#can_read@emea-idp":        true,
+		}, &logger),
+		resourceType: "Group",
+		objectType:   "group",
+	}
+
+	ctx := handlers.WithCaller(context.Background(), "emea-idp")
+
+	// Groups the caller can't read are left out of lists, as they are when read by id.
+	page, err := handler.GetAll(ctx, scim.ListRequestParams{StartIndex: 1, Count: 10})
+	assert.NoError(err)
+	assert.Equal(3, page.TotalResults)
+	assert.NotContains(page.Resources, scim.Resource{ID: "us-admins"})
+
+	_, err = handler.Get(ctx, "us-admins")
+	assertForbidden(t, err)
+
+	// The window of the page applies to the readable groups.
+	page, err = handler.GetAll(ctx, scim.ListRequestParams{StartIndex: 2, Count: 1})
+	assert.NoError(err)
+	assert.Equal(3, page.TotalResults)
+	assert.Equal([]scim.Resource{{ID: "emea-users"}}, page.Resources)
+
+	// A request for the number of results counts the readable groups without listing them.
+	page, err = handler.GetAll(ctx, scim.ListRequestParams{StartIndex: 1, Count: 0})
+	assert.NoError(err)
+	assert.Equal(3, page.TotalResults)
+	assert.Empty(page.Resources)
+}
+
+func TestAuthorizedHandlerGetAllStopsAfterPage(t *testing.T) {
+	assert := require.New(t)
+
+	logger := zerolog.Nop()
+	cfg := &config.AuthzConfig{
+		Enabled:                true,
+		SubjectType:            "scim_client",
+		ResourceTypeObjectType: "scim_resource_type",
+	}
+
+	checker := &countingChecker{fakeChecker: fakeChecker{"scim_resource_type:Group#can_read@emea-idp": true}}
+	ids := make([]string, 0, 1000)
+
+	for i := range cap(ids) {
+		ids = append(ids, fmt.Sprintf("group-%04d", i))
+		checker.fakeChecker[fmt.Sprintf("group:group-%04d#can_read@emea-idp", i)] = i%2 == 0
+	}
+
+	handler := authorizedHandler{
+		handler:      listHandler{ids: ids},
+		authorizer:   newAuthorizer(cfg, checker, &logger),
+		resourceType: "Group",
+		objectType:   "group",
+	}
+
+	ctx := handlers.WithCaller(context.Background(), "emea-idp")
+
+	// The groups past the first readable one that follows the page are neither listed nor checked, and the total
+	// tells the client that more groups follow.
+	page, err := handler.GetAll(ctx, scim.ListRequestParams{StartIndex: 3, Count: 2})
+	assert.NoError(err)
+	assert.Equal([]scim.Resource{{ID: "group-0004"}, {ID: "group-0006"}}, page.Resources)
+	assert.Equal(5, page.TotalResults)
+	assert.Equal(100, checker.checked)
+}
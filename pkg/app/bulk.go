@@ -0,0 +1,553 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+)
+
+const (
+	bulkRequestSchema  = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	bulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	patchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	bulkIDPrefix       = "bulkId:"
+)
+
+type bulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors"`
+	Operations   []bulkOperation `json:"Operations"`
+}
+
+type bulkOperation struct {
+	Method  string `json:"method"`
+	BulkID  string `json:"bulkId"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	Data    any    `json:"data"`
+}
+
+type bulkResponse struct {
+	Schemas    []string                `json:"schemas"`
+	Operations []bulkOperationResponse `json:"Operations"`
+}
+
+type bulkOperationResponse struct {
+	Location string             `json:"location,omitempty"`
+	Method   string             `json:"method"`
+	BulkID   string             `json:"bulkId,omitempty"`
+	Version  string             `json:"version,omitempty"`
+	Status   string             `json:"status"`
+	Response *serrors.ScimError `json:"response,omitempty"`
+}
+
+// bulkHandler processes SCIM bulk requests as defined in RFC 7644 section 3.7 by dispatching
+// each operation to the resource handler of its endpoint.
+type bulkHandler struct {
+	cfg           *config.BulkConfig
+	resourceTypes []scim.ResourceType
+	logger        *zerolog.Logger
+}
+
+func newBulkHandler(cfg *config.BulkConfig, resourceTypes []scim.ResourceType, logger *zerolog.Logger) *bulkHandler {
+	bulkLogger := logger.With().Str("component", "bulk").Logger()
+
+	return &bulkHandler{
+		cfg:           cfg,
+		resourceTypes: resourceTypes,
+		logger:        &bulkLogger,
+	}
+}
+
+// withBulk serves the /Bulk endpoint, which the SCIM library does not implement.
+func withBulk(bulk *bulkHandler, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/v2") != "/Bulk" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bulk.ServeHTTP(w, r)
+	}
+}
+
+func (b *bulkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeScimError(w, serrors.ScimError{Status: http.StatusMethodNotAllowed})
+		return
+	}
+
+	req, scimErr := b.parseRequest(w, r)
+	if scimErr != nil {
+		b.logger.Warn().Int("status", scimErr.Status).Str("detail", scimErr.Detail).Msg("invalid bulk request")
+		writeScimError(w, *scimErr)
+
+		return
+	}
+
+	b.logger.Info().Int("operations", len(req.Operations)).Int("fail_on_errors", req.FailOnErrors).Msg("bulk request")
+
+	resp := bulkResponse{
+		Schemas:    []string{bulkResponseSchema},
+		Operations: b.process(r.Context(), req),
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		writeScimError(w, serrors.ScimErrorInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(raw)
+}
+
+func (b *bulkHandler) parseRequest(w http.ResponseWriter, r *http.Request) (*bulkRequest, *serrors.ScimError) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(b.cfg.MaxPayloadSize)))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &serrors.ScimError{
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("The size of the bulk operation exceeds the maxPayloadSize (%d).", b.cfg.MaxPayloadSize),
+			}
+		}
+
+		return nil, &serrors.ScimErrorInvalidSyntax
+	}
+
+	req := &bulkRequest{}
+	if err := unmarshal(data, req); err != nil {
+		return nil, &serrors.ScimErrorInvalidSyntax
+	}
+
+	if len(req.Schemas) != 1 || req.Schemas[0] != bulkRequestSchema {
+		return nil, &serrors.ScimErrorInvalidValue
+	}
+
+	if len(req.Operations) > b.cfg.MaxOperations {
+		return nil, &serrors.ScimError{
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("The number of operations exceeds the maxOperations (%d).", b.cfg.MaxOperations),
+		}
+	}
+
+	return req, nil
+}
+
+// process runs the operations of a bulk request. Operations that reference the bulkId of a resource created
+// later in the same request are deferred until that resource exists.
+func (b *bulkHandler) process(ctx context.Context, req *bulkRequest) []bulkOperationResponse {
+	results := make([]bulkOperationResponse, 0, len(req.Operations))
+	ids := map[string]string{}
+	failures := 0
+
+	pending := make([]*bulkOperation, 0, len(req.Operations))
+	for i := range req.Operations {
+		pending = append(pending, &req.Operations[i])
+	}
+
+	for len(pending) > 0 {
+		deferred := make([]*bulkOperation, 0)
+		pendingBulkIDs := map[string]bool{}
+
+		for _, op := range pending {
+			if op.BulkID != "" {
+				pendingBulkIDs[op.BulkID] = true
+			}
+		}
+
+		for _, op := range pending {
+			if req.FailOnErrors > 0 && failures >= req.FailOnErrors {
+				return results
+			}
+
+			var result bulkOperationResponse
+
+			switch ref, ok := unresolvedReference(op, ids); {
+			case !ok:
+				result = b.execute(ctx, op, ids)
+			case pendingBulkIDs[ref]:
+				deferred = append(deferred, op)
+				continue
+			default:
+				result = bulkError(op, &serrors.ScimError{
+					Status: http.StatusConflict,
+					Detail: fmt.Sprintf("The bulkId %q does not reference a created resource.", ref),
+				})
+			}
+
+			delete(pendingBulkIDs, op.BulkID)
+
+			if result.Response != nil {
+				failures++
+			}
+
+			results = append(results, result)
+		}
+
+		if len(deferred) == len(pending) {
+			for _, op := range deferred {
+				results = append(results, bulkError(op, &serrors.ScimError{
+					Status: http.StatusConflict,
+					Detail: "The operation has circular bulkId references.",
+				}))
+			}
+
+			break
+		}
+
+		pending = deferred
+	}
+
+	return results
+}
+
+func (b *bulkHandler) execute(ctx context.Context, op *bulkOperation, ids map[string]string) bulkOperationResponse {
+	logger := b.logger.With().Str("caller", handlers.Caller(ctx)).Str("method", op.Method).Str("path", op.Path).
+		Str("bulk_id", op.BulkID).Logger()
+
+	method := strings.ToUpper(op.Method)
+	data := resolveReferences(op.Data, ids)
+
+	resourceType, handler, id, scimErr := b.route(method, resolveReference(op.Path, ids))
+	if scimErr != nil {
+		return bulkError(op, scimErr)
+	}
+
+	if operation := methodOperation(method); !callerFrom(ctx).allowed(resourceType.Name, operation) {
+		logger.Warn().Msg("operation not permitted")
+
+		scimErr := forbidden(operation, resourceType.Name)
+
+		return bulkError(op, &scimErr)
+	}
+
+	result := bulkOperationResponse{Method: method, BulkID: op.BulkID}
+	ctx = handlers.WithIfMatch(ctx, op.Version)
+
+	var (
+		resource scim.Resource
+		err      error
+	)
+
+	switch method {
+	case http.MethodPost:
+		if op.BulkID == "" {
+			return bulkError(op, &serrors.ScimError{Status: http.StatusBadRequest, Detail: "POST operations require a bulkId."})
+		}
+
+		resource, err = validateAndRun(resourceType, data, func(attributes scim.ResourceAttributes) (scim.Resource, error) {
+			return handler.Create(ctx, attributes)
+		})
+		result.Status = strconv.Itoa(http.StatusCreated)
+	case http.MethodPut:
+		resource, err = validateAndRun(resourceType, data, func(attributes scim.ResourceAttributes) (scim.Resource, error) {
+			return handler.Replace(ctx, id, attributes)
+		})
+		result.Status = strconv.Itoa(http.StatusOK)
+	case http.MethodPatch:
+		var operations []scim.PatchOperation
+
+		operations, err = parsePatchOperations(resourceType, data)
+		if err == nil {
+			resource, err = handler.Patch(ctx, id, operations)
+		}
+
+		result.Status = strconv.Itoa(http.StatusOK)
+	case http.MethodDelete:
+		err = handler.Delete(ctx, id)
+		resource = scim.Resource{ID: id}
+		result.Status = strconv.Itoa(http.StatusNoContent)
+	}
+
+	if err != nil {
+		logger.Err(err).Msg("bulk operation failed")
+
+		scimErr := serrors.CheckScimError(err, method)
+
+		return bulkError(op, &scimErr)
+	}
+
+	if method == http.MethodPost {
+		ids[op.BulkID] = resource.ID
+	}
+
+	result.Location = fmt.Sprintf("%s/%s", resourceType.Endpoint[1:], url.PathEscape(resource.ID))
+	result.Version = resource.Meta.Version
+
+	logger.Trace().Str("id", resource.ID).Msg("bulk operation completed")
+
+	return result
+}
+
+// route finds the resource type and handler addressed by the path of a bulk operation.
+func (b *bulkHandler) route(
+	method, path string,
+) (scim.ResourceType, handlers.ResourceHandler, string, *serrors.ScimError) {
+	path = strings.TrimPrefix(path, "/v2")
+
+	for _, resourceType := range b.resourceTypes {
+		resourceHandler, ok := resourceType.Handler.(*ResourceHandler)
+		if !ok {
+			continue
+		}
+
+		if path == resourceType.Endpoint && method == http.MethodPost {
+			return resourceType, resourceHandler.handler, "", nil
+		}
+
+		if !strings.HasPrefix(path, resourceType.Endpoint+"/") {
+			continue
+		}
+
+		switch method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			id, err := url.PathUnescape(strings.TrimPrefix(path, resourceType.Endpoint+"/"))
+			if err != nil || id == "" {
+				return scim.ResourceType{}, nil, "", &serrors.ScimErrorInvalidPath
+			}
+
+			return resourceType, resourceHandler.handler, id, nil
+		}
+	}
+
+	return scim.ResourceType{}, nil, "", &serrors.ScimError{
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("The %s operation is not supported on %q.", method, path),
+	}
+}
+
+func validateAndRun(
+	resourceType scim.ResourceType,
+	data any,
+	run func(attributes scim.ResourceAttributes) (scim.Resource, error),
+) (scim.Resource, error) {
+	attributes, err := validateAttributes(resourceType, data)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	return run(attributes)
+}
+
+// validateAttributes validates the data of a bulk operation against the schema and schema extensions
+// of the resource type, the same way the SCIM server validates POST and PUT requests.
+func validateAttributes(resourceType scim.ResourceType, data any) (scim.ResourceAttributes, error) {
+	m, ok := data.(map[string]any)
+	if !ok {
+		return nil, serrors.ScimErrorInvalidSyntax
+	}
+
+	attributes, scimErr := schemaWithCommon(resourceType).Validate(m)
+	if scimErr != nil {
+		return nil, *scimErr
+	}
+
+	for _, extension := range resourceType.SchemaExtensions {
+		extensionField := m[extension.Schema.ID]
+		if extensionField == nil {
+			if extension.Required {
+				return nil, serrors.ScimErrorInvalidValue
+			}
+
+			continue
+		}
+
+		extensionAttributes, scimErr := extension.Schema.Validate(extensionField)
+		if scimErr != nil {
+			return nil, *scimErr
+		}
+
+		attributes[extension.Schema.ID] = extensionAttributes
+	}
+
+	return attributes, nil
+}
+
+// parsePatchOperations parses and validates the PatchOp message of a bulk PATCH operation.
+func parsePatchOperations(resourceType scim.ResourceType, data any) ([]scim.PatchOperation, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, serrors.ScimErrorInvalidSyntax
+	}
+
+	var req struct {
+		Schemas    []string
+		Operations []struct {
+			Op    string
+			Path  string
+			Value any
+		}
+	}
+	if err := unmarshal(raw, &req); err != nil {
+		return nil, serrors.ScimErrorInvalidSyntax
+	}
+
+	if len(req.Schemas) != 1 || req.Schemas[0] != patchOpSchema || len(req.Operations) == 0 {
+		return nil, serrors.ScimErrorInvalidValue
+	}
+
+	extensions := make([]schema.Schema, 0, len(resourceType.SchemaExtensions))
+	for _, extension := range resourceType.SchemaExtensions {
+		extensions = append(extensions, extension.Schema)
+	}
+
+	operations := make([]scim.PatchOperation, 0, len(req.Operations))
+
+	for _, v := range req.Operations {
+		op := scim.PatchOperation{Op: strings.ToLower(v.Op), Value: v.Value}
+
+		switch op.Op {
+		case scim.PatchOperationAdd, scim.PatchOperationReplace, scim.PatchOperationRemove:
+		default:
+			return nil, serrors.ScimErrorInvalidValue
+		}
+
+		if v.Path != "" {
+			validator, err := filter.NewPathValidator(v.Path, schemaWithCommon(resourceType), extensions...)
+			if err != nil || validator.Validate() != nil {
+				return nil, serrors.ScimErrorInvalidPath
+			}
+
+			path := validator.Path()
+			op.Path = &path
+		} else if op.Op == scim.PatchOperationRemove {
+			return nil, serrors.ScimErrorNoTarget
+		}
+
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
+
+func schemaWithCommon(resourceType scim.ResourceType) schema.Schema {
+	s := resourceType.Schema
+	s.Attributes = append(s.Attributes[:len(s.Attributes):len(s.Attributes)], schema.SimpleCoreAttribute(
+		schema.SimpleStringParams(schema.StringParams{
+			CaseExact:  true,
+			Mutability: schema.AttributeMutabilityReadWrite(),
+			Name:       schema.CommonAttributeExternalID,
+			Uniqueness: schema.AttributeUniquenessNone(),
+		}),
+	))
+
+	return s
+}
+
+// unresolvedReference returns the first bulkId referenced by the operation that has no created resource yet.
+func unresolvedReference(op *bulkOperation, ids map[string]string) (string, bool) {
+	var refs []string
+
+	collectReferences(op.Path, &refs)
+	collectReferences(op.Data, &refs)
+
+	for _, ref := range refs {
+		if _, ok := ids[ref]; !ok {
+			return ref, true
+		}
+	}
+
+	return "", false
+}
+
+func collectReferences(value any, refs *[]string) {
+	switch v := value.(type) {
+	case string:
+		if _, ref, ok := strings.Cut(v, bulkIDPrefix); ok {
+			*refs = append(*refs, ref)
+		}
+	case map[string]any:
+		for _, item := range v {
+			collectReferences(item, refs)
+		}
+	case []any:
+		for _, item := range v {
+			collectReferences(item, refs)
+		}
+	}
+}
+
+// resolveReferences returns a copy of value where every "bulkId:<id>" reference is replaced with
+// the id of the resource created by that bulk operation.
+func resolveReferences(value any, ids map[string]string) any {
+	switch v := value.(type) {
+	case string:
+		return resolveReference(v, ids)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = resolveReferences(item, ids)
+		}
+
+		return result
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, item := range v {
+			result = append(result, resolveReferences(item, ids))
+		}
+
+		return result
+	default:
+		return value
+	}
+}
+
+func resolveReference(value string, ids map[string]string) string {
+	prefix, ref, ok := strings.Cut(value, bulkIDPrefix)
+	if !ok {
+		return value
+	}
+
+	id, ok := ids[ref]
+	if !ok {
+		return value
+	}
+
+	return prefix + id
+}
+
+func bulkError(op *bulkOperation, scimErr *serrors.ScimError) bulkOperationResponse {
+	return bulkOperationResponse{
+		Method:   strings.ToUpper(op.Method),
+		BulkID:   op.BulkID,
+		Status:   strconv.Itoa(scimErr.Status),
+		Response: scimErr,
+	}
+}
+
+func writeScimError(w http.ResponseWriter, scimErr serrors.ScimError) {
+	raw, err := json.Marshal(scimErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(scimErr.Status)
+	_, _ = w.Write(raw)
+}
+
+func unmarshal(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	serrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryHandler struct {
+	resources map[string]scim.ResourceAttributes
+}
+
+func (h *memoryHandler) Create(_ context.Context, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	id := strconv.Itoa(len(h.resources) + 1)
+	h.resources[id] = attributes
+
+	return scim.Resource{ID: id, Attributes: attributes}, nil
+}
+
+func (h *memoryHandler) Get(_ context.Context, id string) (scim.Resource, error) {
+	attributes, ok := h.resources[id]
+	if !ok {
+		return scim.Resource{}, serrors.ScimErrorResourceNotFound(id)
+	}
+
+	return scim.Resource{ID: id, Attributes: attributes}, nil
+}
+
+func (h *memoryHandler) GetAll(_ context.Context, _ scim.ListRequestParams) (scim.Page, error) {
+	return scim.Page{}, nil
+}
+
+func (h *memoryHandler) Patch(ctx context.Context, id string, _ []scim.PatchOperation) (scim.Resource, error) {
+	return h.Get(ctx, id)
+}
+
+func (h *memoryHandler) Replace(_ context.Context, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	if _, ok := h.resources[id]; !ok {
+		return scim.Resource{}, serrors.ScimErrorResourceNotFound(id)
+	}
+
+	h.resources[id] = attributes
+
+	return scim.Resource{ID: id, Attributes: attributes}, nil
+}
+
+func (h *memoryHandler) Delete(_ context.Context, id string) error {
+	if _, ok := h.resources[id]; !ok {
+		return serrors.ScimErrorResourceNotFound(id)
+	}
+
+	delete(h.resources, id)
+
+	return nil
+}
+
+func newTestBulkHandler(cfg *config.BulkConfig) (*bulkHandler, *memoryHandler, *memoryHandler) {
+	users := &memoryHandler{resources: map[string]scim.ResourceAttributes{}}
+	groups := &memoryHandler{resources: map[string]scim.ResourceAttributes{}}
+	logger := zerolog.Nop()
+
+	resourceTypes := []scim.ResourceType{
+		{
+			ID:       optional.NewString("User"),
+			Name:     "User",
+			Endpoint: "/Users",
+			Schema:   schema.CoreUserSchema(),
+			Handler:  &ResourceHandler{handler: users},
+		},
+		{
+			ID:       optional.NewString("Group"),
+			Name:     "Group",
+			Endpoint: "/Groups",
+			Schema:   schema.CoreGroupSchema(),
+			Handler:  &ResourceHandler{handler: groups},
+		},
+	}
+
+	return newBulkHandler(cfg, resourceTypes, &logger), users, groups
+}
+
+func serveBulk(t *testing.T, bulk *bulkHandler, body string) (int, bulkResponse) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	bulk.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(body)))
+
+	var resp bulkResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+
+	return w.Code, resp
+}
+
+func TestBulkResolvesBulkIDReferences(t *testing.T) {
+	assert := require.New(t)
+	bulk, users, groups := newTestBulkHandler(&config.BulkConfig{MaxOperations: 10, MaxPayloadSize: 4096})
+
+	status, resp := serveBulk(t, bulk, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Groups", "bulkId": "admins",
+			 "data": {"displayName": "admins", "members": [{"value": "bulkId:rick"}]}},
+			{"method": "POST", "path": "/Users", "bulkId": "rick", "data": {"userName": "rick"}}
+		]
+	}`)
+	assert.Equal(http.StatusOK, status)
+	assert.Len(resp.Operations, 2)
+
+	assert.Equal("rick", resp.Operations[0].BulkID)
+	assert.Equal("201", resp.Operations[0].Status)
+	assert.Equal("Users/1", resp.Operations[0].Location)
+
+	assert.Equal("admins", resp.Operations[1].BulkID)
+	assert.Equal("201", resp.Operations[1].Status)
+	assert.Equal("rick", users.resources["1"]["userName"])
+
+	members, ok := groups.resources["1"]["members"].([]any)
+	assert.True(ok)
+	assert.Equal("1", members[0].(map[string]any)["value"])
+}
+
+func TestBulkFailOnErrors(t *testing.T) {
+	assert := require.New(t)
+	bulk, _, _ := newTestBulkHandler(&config.BulkConfig{MaxOperations: 10, MaxPayloadSize: 4096})
+
+	status, resp := serveBulk(t, bulk, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{"method": "DELETE", "path": "/Users/404"},
+			{"method": "POST", "path": "/Users", "bulkId": "rick", "data": {"userName": "rick"}}
+		]
+	}`)
+	assert.Equal(http.StatusOK, status)
+	assert.Len(resp.Operations, 1)
+	assert.Equal("404", resp.Operations[0].Status)
+	assert.NotNil(resp.Operations[0].Response)
+}
+
+func TestBulkLimits(t *testing.T) {
+	assert := require.New(t)
+	bulk, _, _ := newTestBulkHandler(&config.BulkConfig{MaxOperations: 1, MaxPayloadSize: 4096})
+
+	status, _ := serveBulk(t, bulk, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "DELETE", "path": "/Users/1"},
+			{"method": "DELETE", "path": "/Users/2"}
+		]
+	}`)
+	assert.Equal(http.StatusRequestEntityTooLarge, status)
+
+	bulk.cfg.MaxPayloadSize = 16
+	status, _ = serveBulk(t, bulk, `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"]}`)
+	assert.Equal(http.StatusRequestEntityTooLarge, status)
+}
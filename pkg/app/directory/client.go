@@ -5,8 +5,8 @@ import (
 	"github.com/aserto-dev/go-aserto/ds/v3"
 )
 
-func GetDirectoryClient(cfg *client.Config) (*ds.Client, error) {
-	conn, err := cfg.Connect()
+func GetDirectoryClient(cfg *client.Config, opts ...client.ConnectionOption) (*ds.Client, error) {
+	conn, err := cfg.Connect(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/aserto-dev/scim/common/convert"
+)
+
+// withIfNoneMatch answers GET requests with 304 Not Modified when the ETag of the returned resource
+// matches the If-None-Match request header.
+func withIfNoneMatch(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if r.Method != http.MethodGet || ifNoneMatch == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&notModifiedWriter{ResponseWriter: w, ifNoneMatch: ifNoneMatch}, r)
+	}
+}
+
+type notModifiedWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *notModifiedWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if status == http.StatusOK && convert.MatchETag(w.ifNoneMatch, w.Header().Get("Etag")) {
+		w.notModified = true
+		w.Header().Del("Content-Type")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notModifiedWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.notModified {
+		return len(data), nil
+	}
+
+	return w.ResponseWriter.Write(data)
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/aserto-dev/scim/common/handlers"
@@ -22,7 +23,7 @@ func (g ResourceHandler) Create(r *http.Request, attributes scim.ResourceAttribu
 }
 
 func (g ResourceHandler) Delete(r *http.Request, id string) error {
-	return g.handler.Delete(r.Context(), id)
+	return g.handler.Delete(conditionalContext(r), id)
 }
 
 func (g ResourceHandler) Get(r *http.Request, id string) (scim.Resource, error) {
@@ -34,9 +35,14 @@ func (g ResourceHandler) GetAll(r *http.Request, params scim.ListRequestParams)
 }
 
 func (g ResourceHandler) Patch(r *http.Request, id string, operations []scim.PatchOperation) (scim.Resource, error) {
-	return g.handler.Patch(r.Context(), id, operations)
+	return g.handler.Patch(conditionalContext(r), id, operations)
 }
 
 func (g ResourceHandler) Replace(r *http.Request, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
-	return g.handler.Replace(r.Context(), id, attributes)
+	return g.handler.Replace(conditionalContext(r), id, attributes)
+}
+
+// conditionalContext returns the request context carrying the If-Match precondition of the request.
+func conditionalContext(r *http.Request) context.Context {
+	return handlers.WithIfMatch(r.Context(), r.Header.Get("If-Match"))
 }
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	dsm "github.com/aserto-dev/go-directory/aserto/directory/model/v3"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"gopkg.in/yaml.v3"
+)
+
+// readinessTimeout bounds the directory calls of a readiness check.
+const readinessTimeout = 5 * time.Second
+
+var ErrModelMismatch = errors.New("directory model mismatch")
+
+// manifest is the part of a directory manifest that declares object types and their relations.
+type manifest struct {
+	Types map[string]struct {
+		Relations map[string]any `yaml:"relations"`
+	} `yaml:"types"`
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// withHealth serves the unauthenticated /healthz liveness and /readyz readiness endpoints, and passes other
+// requests to next.
+func (s *SCIMServer) withHealth(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/healthz":
+			writeHealth(w, http.StatusOK, &healthStatus{Status: "ok"})
+		case "/readyz":
+			s.ready(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// ready checks the directory of the server, or of each tenant, and responds with 503 Service Unavailable if one
+// of them isn't ready.
+func (s *SCIMServer) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	servers := s.tenants
+	if len(servers) == 0 {
+		servers = []*SCIMServer{s}
+	}
+
+	result := &healthStatus{Status: "ready", Checks: map[string]string{}}
+	status := http.StatusOK
+
+	for _, server := range servers {
+		name := server.tenant
+		if name == "" {
+			name = "directory"
+		}
+
+		if err := server.checkDirectory(ctx); err != nil {
+			server.log.Warn().Err(err).Msg("readiness check failed")
+
+			result.Checks[name] = err.Error()
+			result.Status = "not ready"
+			status = http.StatusServiceUnavailable
+
+			continue
+		}
+
+		result.Checks[name] = "ok"
+	}
+
+	writeHealth(w, status, result)
+}
+
+// checkDirectory verifies that the directory is reachable and that its model declares the object types and
+// relations used by the SCIM configuration.
+func (s *SCIMServer) checkDirectory(ctx context.Context) error {
+	data, err := readManifest(ctx, s.dsClient.Model)
+	if err != nil {
+		return errors.Wrap(err, "failed to read directory manifest")
+	}
+
+	return checkModel(data, s.cfg.SCIM.RequiredModel())
+}
+
+func readManifest(ctx context.Context, client dsm.ModelClient) ([]byte, error) {
+	stream, err := client.GetManifest(ctx, &dsm.GetManifestRequest{Empty: &emptypb.Empty{}})
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return data, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, msg.GetBody().GetData()...)
+	}
+}
+
+// checkModel returns ErrModelMismatch if the manifest doesn't declare one of the required object types or relations.
+func checkModel(data []byte, required map[string][]string) error {
+	var m manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return errors.Wrap(err, "failed to parse directory manifest")
+	}
+
+	for _, objectType := range slices.Sorted(maps.Keys(required)) {
+		declared, ok := m.Types[objectType]
+		if !ok {
+			return errors.Wrapf(ErrModelMismatch, "object type [%s] is not declared", objectType)
+		}
+
+		for _, relation := range required[objectType] {
+			if _, ok := declared.Relations[relation]; !ok {
+				return errors.Wrapf(ErrModelMismatch, "relation [%s#%s] is not declared", objectType, relation)
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeHealth(w http.ResponseWriter, status int, result *healthStatus) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(raw)
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aserto-dev/go-aserto/ds/v3"
+	dsm "github.com/aserto-dev/go-directory/aserto/directory/model/v3"
+	commonconfig "github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+const testManifest = `
+model:
+  version: 3
+
+types:
+  user:
+    relations:
+      manager: user
+  identity:
+    relations:
+      identifier: user
+  group:
+    relations:
+      member: user | group#member
+  scim-user: {}
+  scim-group: {}
+`
+
+type fakeModelClient struct {
+	dsm.ModelClient
+	manifest string
+}
+
+func (c *fakeModelClient) GetManifest(
+	context.Context,
+	*dsm.GetManifestRequest,
+	...grpc.CallOption,
+) (grpc.ServerStreamingClient[dsm.GetManifestResponse], error) {
+	return &manifestStream{chunks: []string{c.manifest[:10], c.manifest[10:]}}, nil
+}
+
+type manifestStream struct {
+	grpc.ClientStream
+	chunks []string
+}
+
+func (s *manifestStream) Recv() (*dsm.GetManifestResponse, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+
+	return &dsm.GetManifestResponse{Msg: &dsm.GetManifestResponse_Body{Body: &dsm.Body{Data: []byte(chunk)}}}, nil
+}
+
+func TestHealth(t *testing.T) {
+	scimCfg := func(managerRelation string) commonconfig.Config {
+		return commonconfig.Config{
+			User: &commonconfig.User{
+				ObjectType:         "user",
+				IdentityObjectType: "identity",
+				IdentityRelation:   "identity#identifier",
+				SourceObjectType:   "scim-user",
+				ManagerRelation:    managerRelation,
+			},
+			Group: &commonconfig.Group{ObjectType: "group", GroupMemberRelation: "member", SourceObjectType: "scim-group"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		scim   commonconfig.Config
+		status int
+		check  string
+	}{
+		{name: "liveness", path: "/healthz", scim: scimCfg("reports_to"), status: http.StatusOK},
+		{name: "ready", path: "/readyz", scim: scimCfg("manager"), status: http.StatusOK, check: "ok"},
+		{
+			name:   "missing relation",
+			path:   "/readyz",
+			scim:   scimCfg("reports_to"),
+			status: http.StatusServiceUnavailable,
+			check:  "relation [user#reports_to] is not declared: directory model mismatch",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			log := zerolog.Nop()
+			cfg := &config.Config{SCIM: tc.scim}
+			s := &SCIMServer{
+				log:      &log,
+				cfg:      cfg,
+				dsClient: &ds.Client{Model: &fakeModelClient{manifest: testManifest}},
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+
+			rec := httptest.NewRecorder()
+			s.withHealth(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, http.NoBody))
+
+			assert.Equal(tc.status, rec.Code)
+
+			var result healthStatus
+			assert.NoError(json.Unmarshal(rec.Body.Bytes(), &result))
+
+			if tc.check != "" {
+				assert.Equal(tc.check, result.Checks["directory"])
+			}
+		})
+	}
+}
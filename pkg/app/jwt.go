@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+)
+
+var ErrMissingScope = errors.New("token is missing the required scope")
+
+// scopeClaims are the claims in which identity providers list the scopes of a token: "scope" (RFC 8693) holds
+// a space-separated string, "scp" is used by Entra ID as a string and by Okta as an array.
+var scopeClaims = []string{"scope", "scp"}
+
+// jwtValidator validates JWT bearer tokens against the keys of a JWKS and the configured claims.
+type jwtValidator struct {
+	cfg  *config.JWTConfig
+	keys jwk.Set
+}
+
+// newJWTValidator reads the JWKS file, or fetches the JWKS from its URL and keeps it in a cache that is refreshed
+// in the background until ctx is done.
+func newJWTValidator(ctx context.Context, cfg *config.JWTConfig) (*jwtValidator, error) {
+	if cfg.JWKSFile != "" {
+		keys, err := jwk.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read jwks file '%s'", cfg.JWKSFile)
+		}
+
+		return &jwtValidator{cfg: cfg, keys: keys}, nil
+	}
+
+	cache := jwk.NewCache(ctx)
+	if err := cache.Register(cfg.JWKSURL, jwk.WithMinRefreshInterval(cfg.RefreshInterval)); err != nil {
+		return nil, errors.Wrapf(err, "failed to register jwks url '%s'", cfg.JWKSURL)
+	}
+
+	if _, err := cache.Refresh(ctx, cfg.JWKSURL); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch jwks from '%s'", cfg.JWKSURL)
+	}
+
+	return &jwtValidator{cfg: cfg, keys: jwk.NewCachedSet(cache, cfg.JWKSURL)}, nil
+}
+
+// Validate verifies the signature, issuer, audience, expiry and scope of a token. The algorithm of keys without
+// "alg", such as those published by Entra ID, is inferred from the key type.
+func (v *jwtValidator) Validate(token string) (jwt.Token, error) {
+	parsed, err := jwt.ParseString(token,
+		jwt.WithKeySet(v.keys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithAcceptableSkew(v.cfg.AcceptableSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.cfg.RequiredScope != "" && !hasScope(parsed, v.cfg.RequiredScope) {
+		return nil, ErrMissingScope
+	}
+
+	return parsed, nil
+}
+
+func hasScope(token jwt.Token, scope string) bool {
+	for _, claim := range scopeClaims {
+		value, ok := token.Get(claim)
+		if !ok {
+			continue
+		}
+
+		switch scopes := value.(type) {
+		case string:
+			if slices.Contains(strings.Fields(scopes), scope) {
+				return true
+			}
+		case []any:
+			if slices.Contains(scopes, any(scope)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
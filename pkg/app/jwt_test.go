@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+// writeJWKS writes the public key of key to a JWKS file and returns its path.
+func writeJWKS(t *testing.T, key jwk.Key) string {
+	t.Helper()
+
+	publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(publicKey))
+
+	jwks, err := json.Marshal(keys)
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	return jwksFile
+}
+
+func TestJWTValidator(t *testing.T) {
+	assert := require.New(t)
+
+	rawKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	key, err := jwk.FromRaw(rawKey)
+	assert.NoError(err)
+	assert.NoError(key.Set(jwk.KeyIDKey, "scim"))
+	assert.NoError(key.Set(jwk.AlgorithmKey, jwa.RS256))
+
+	validator, err := newJWTValidator(context.Background(), &config.JWTConfig{
+		Enabled:       true,
+		JWKSFile:      writeJWKS(t, key),
+		Issuer:        "https://idp.example.com",
+		Audience:      "scim",
+		RequiredScope: "scim.write",
+	})
+	assert.NoError(err)
+
+	sign := func(claims map[string]any) string {
+		builder := jwt.NewBuilder().
+			Issuer("https://idp.example.com").
+			Audience([]string{"scim"}).
+			Expiration(time.Now().Add(time.Hour))
+		for name, value := range claims {
+			builder = builder.Claim(name, value)
+		}
+
+		token, err := builder.Build()
+		assert.NoError(err)
+
+		signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+		assert.NoError(err)
+
+		return string(signed)
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		valid  bool
+	}{
+		{name: "scope string", claims: map[string]any{"scope": "scim.read scim.write"}, valid: true},
+		{name: "scp array", claims: map[string]any{"scp": []string{"scim.write"}}, valid: true},
+		{name: "missing scope", claims: map[string]any{"scope": "scim.read"}},
+		{name: "wrong issuer", claims: map[string]any{"scope": "scim.write", "iss": "https://evil.example.com"}},
+		{name: "wrong audience", claims: map[string]any{"scope": "scim.write", "aud": "other"}},
+		{name: "expired", claims: map[string]any{"scope": "scim.write", "exp": time.Now().Add(-time.Hour).Unix()}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validator.Validate(sign(tc.claims))
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	_, err = validator.Validate("not-a-jwt")
+	assert.Error(err)
+}
+
+func TestJWTValidatorKeyWithoutAlgorithm(t *testing.T) {
+	assert := require.New(t)
+
+	rawKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	// Entra ID publishes its signing keys without "alg".
+	key, err := jwk.FromRaw(rawKey)
+	assert.NoError(err)
+	assert.NoError(key.Set(jwk.KeyIDKey, "entra"))
+
+	validator, err := newJWTValidator(context.Background(), &config.JWTConfig{
+		Enabled:  true,
+		JWKSFile: writeJWKS(t, key),
+		Issuer:   "https://login.microsoftonline.com/tenant/v2.0",
+		Audience: "scim",
+	})
+	assert.NoError(err)
+
+	token, err := jwt.NewBuilder().
+		Issuer("https://login.microsoftonline.com/tenant/v2.0").
+		Audience([]string{"scim"}).
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	assert.NoError(err)
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+	assert.NoError(err)
+
+	_, err = validator.Validate(string(signed))
+	assert.NoError(err)
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/elimity-com/scim"
+)
+
+// withItemsPerPage rewrites the itemsPerPage of list responses to the number of resources on the page,
+// as required by RFC 7644 section 3.4.2. The SCIM server reports the requested count instead.
+func withItemsPerPage(endpoints []string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !slices.Contains(endpoints, strings.TrimPrefix(r.URL.Path, "/v2")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+		if buf.status == http.StatusOK {
+			body = setItemsPerPage(body)
+		}
+
+		w.WriteHeader(buf.status)
+		_, _ = w.Write(body)
+	}
+}
+
+func setItemsPerPage(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var listResponse map[string]any
+	if err := decoder.Decode(&listResponse); err != nil {
+		return body
+	}
+
+	resources, _ := listResponse["Resources"].([]any)
+	listResponse["itemsPerPage"] = len(resources)
+
+	result, err := json.Marshal(listResponse)
+	if err != nil {
+		return body
+	}
+
+	return result
+}
+
+func resourceEndpoints(resourceTypes []scim.ResourceType) []string {
+	endpoints := make([]string, 0, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		endpoints = append(endpoints, resourceType.Endpoint)
+	}
+
+	return endpoints
+}
+
+// bufferedResponseWriter holds the status and body of a response until the wrapping handler writes it out.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
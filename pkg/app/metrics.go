@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics collects the Prometheus metrics of SCIM requests and directory calls. Requests are labeled with the
+// resource type of their endpoint, and all metrics with the tenant serving them, which is empty without tenants.
+type metrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	directoryDuration *prometheus.HistogramVec
+	directoryFailures *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scim_requests_total",
+			Help: "Number of SCIM requests by resource type, method and status code.",
+		}, []string{"tenant", "resource_type", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scim_request_duration_seconds",
+			Help:    "Duration of SCIM requests by resource type and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tenant", "resource_type", "method"}),
+		directoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scim_directory_request_duration_seconds",
+			Help:    "Duration of directory calls by gRPC method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tenant", "method"}),
+		directoryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scim_directory_request_failures_total",
+			Help: "Number of failed directory calls by gRPC method and status code.",
+		}, []string{"tenant", "method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.directoryDuration,
+		m.directoryFailures,
+	)
+
+	return m
+}
+
+// handler serves the collected metrics.
+func (m *metrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry}))
+
+	return mux
+}
+
+// instrument counts and times the requests served by next.
+func (m *metrics) instrument(tenant string, resourceTypes []scim.ResourceType, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r)
+
+		resourceType := requestResourceType(resourceTypes, r.URL.Path)
+		m.requests.WithLabelValues(tenant, resourceType, r.Method, strconv.Itoa(sw.status)).Inc()
+		m.requestDuration.WithLabelValues(tenant, resourceType, r.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// directoryInterceptor times the directory calls of a tenant and counts the failed ones.
+func (m *metrics) directoryInterceptor(tenant string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		m.directoryDuration.WithLabelValues(tenant, method).Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			m.directoryFailures.WithLabelValues(tenant, method, status.Code(err).String()).Inc()
+		}
+
+		return err
+	}
+}
+
+// requestResourceType returns the name of the resource type served on the endpoint of a request path, or the
+// name of the SCIM endpoint such as "Bulk". Paths of unknown endpoints return "other".
+func requestResourceType(resourceTypes []scim.ResourceType, path string) string {
+	path = strings.TrimPrefix(path, "/v2")
+
+	for _, resourceType := range resourceTypes {
+		if path == resourceType.Endpoint || strings.HasPrefix(path, resourceType.Endpoint+"/") {
+			return resourceType.Name
+		}
+	}
+
+	endpoint, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+
+	switch endpoint {
+	case "Bulk", "ServiceProviderConfig", "Schemas", "ResourceTypes":
+		return endpoint
+	default:
+		return "other"
+	}
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
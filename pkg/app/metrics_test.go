@@ -0,0 +1,48 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsInstrument(t *testing.T) {
+	assert := require.New(t)
+
+	m := newMetrics()
+	resourceTypes := []scim.ResourceType{{Name: "User", Endpoint: "/Users"}, {Name: "Group", Endpoint: "/Groups"}}
+
+	handler := m.instrument("acme", resourceTypes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("{}"))
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/Users/rick", http.NoBody),
+		httptest.NewRequest(http.MethodGet, "/v2/Users", http.NoBody),
+		httptest.NewRequest(http.MethodDelete, "/Groups/admins", http.NoBody),
+		httptest.NewRequest(http.MethodPost, "/Bulk", http.NoBody),
+		httptest.NewRequest(http.MethodGet, "/Usersx", http.NoBody),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.InDelta(2, testutil.ToFloat64(m.requests.WithLabelValues("acme", "User", http.MethodGet, "200")), 0)
+	assert.InDelta(1, testutil.ToFloat64(m.requests.WithLabelValues("acme", "Group", http.MethodDelete, "404")), 0)
+	assert.InDelta(1, testutil.ToFloat64(m.requests.WithLabelValues("acme", "Bulk", http.MethodPost, "200")), 0)
+	assert.InDelta(1, testutil.ToFloat64(m.requests.WithLabelValues("acme", "other", http.MethodGet, "200")), 0)
+
+	rec := httptest.NewRecorder()
+	m.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Contains(rec.Body.String(), `scim_request_duration_seconds_count{method="GET",resource_type="User",tenant="acme"} 2`)
+}
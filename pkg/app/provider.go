@@ -0,0 +1,128 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/optional"
+)
+
+// serviceProviderConfig describes the SCIM features of the server. It extends scim.ServiceProviderConfig
+// with the capabilities that the SCIM library always reports as unsupported.
+type serviceProviderConfig struct {
+	scim.ServiceProviderConfig
+	SupportETag        bool
+	MaxBulkOperations  int
+	MaxBulkPayloadSize int
+}
+
+func (c *serviceProviderConfig) raw() map[string]any {
+	schemes := make([]map[string]any, 0, len(c.AuthenticationSchemes))
+	for _, scheme := range c.AuthenticationSchemes {
+		schemes = append(schemes, map[string]any{
+			"type":             scheme.Type,
+			"name":             scheme.Name,
+			"description":      scheme.Description,
+			"specUri":          scheme.SpecURI.Value(),
+			"documentationUri": scheme.DocumentationURI.Value(),
+			"primary":          scheme.Primary,
+		})
+	}
+
+	raw := map[string]any{
+		"schemas": []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":   map[string]bool{"supported": c.SupportPatch},
+		"bulk": map[string]any{
+			"supported":      c.MaxBulkOperations > 0,
+			"maxOperations":  c.MaxBulkOperations,
+			"maxPayloadSize": c.MaxBulkPayloadSize,
+		},
+		"filter": map[string]any{
+			"supported":  c.SupportFiltering,
+			"maxResults": c.MaxResults,
+		},
+		"changePassword":        map[string]bool{"supported": false},
+		"sort":                  map[string]bool{"supported": false},
+		"etag":                  map[string]bool{"supported": c.SupportETag},
+		"authenticationSchemes": schemes,
+		"meta": map[string]string{
+			"resourceType": "ServiceProviderConfig",
+			"location":     "/ServiceProviderConfig",
+		},
+	}
+
+	if c.DocumentationURI.Present() {
+		raw["documentationUri"] = c.DocumentationURI.Value()
+	}
+
+	return raw
+}
+
+// withServiceProviderConfig serves the /ServiceProviderConfig endpoint from cfg instead of the SCIM library.
+func withServiceProviderConfig(cfg *serviceProviderConfig, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || strings.TrimPrefix(r.URL.Path, "/v2") != "/ServiceProviderConfig" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw, err := json.Marshal(cfg.raw())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/scim+json")
+		_, _ = w.Write(raw)
+	}
+}
+
+// serviceProviderConfig returns the SCIM features of the server, as enabled by its configuration.
+func (s *SCIMServer) serviceProviderConfig() *serviceProviderConfig {
+	return &serviceProviderConfig{
+		ServiceProviderConfig: scim.ServiceProviderConfig{
+			DocumentationURI:      optionalString(s.cfg.Server.DocumentationURI),
+			MaxResults:            s.cfg.Server.MaxResults,
+			SupportFiltering:      true,
+			SupportPatch:          true,
+			AuthenticationSchemes: s.authenticationSchemes(),
+		},
+		SupportETag:        true,
+		MaxBulkOperations:  s.cfg.Server.Bulk.MaxOperations,
+		MaxBulkPayloadSize: s.cfg.Server.Bulk.MaxPayloadSize,
+	}
+}
+
+// authenticationSchemes returns the schemes accepted by the auth configuration. The first scheme is primary.
+// Client certificates have no SCIM authentication scheme type and are not advertised.
+func (s *SCIMServer) authenticationSchemes() []scim.AuthenticationScheme {
+	auth := &s.cfg.Server.Auth
+	schemes := make([]scim.AuthenticationScheme, 0, 2)
+
+	if auth.Basic.Enabled || slices.ContainsFunc(auth.Credentials, isBasicCredential) {
+		schemes = append(schemes, scim.AuthenticationScheme{
+			Type:        scim.AuthenticationTypeHTTPBasic,
+			Name:        "HTTP Basic",
+			Description: "Authentication scheme using the HTTP Basic Standard",
+			SpecURI:     optional.NewString("https://tools.ietf.org/html/rfc7617"),
+		})
+	}
+
+	if auth.Bearer.Enabled || auth.JWT.Enabled || slices.ContainsFunc(auth.Credentials, isTokenCredential) {
+		schemes = append(schemes, scim.AuthenticationScheme{
+			Type:        scim.AuthenticationTypeOauthBearerToken,
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication scheme using the OAuth Bearer Token Standard",
+			SpecURI:     optional.NewString("https://tools.ietf.org/html/rfc6750"),
+		})
+	}
+
+	if len(schemes) > 0 {
+		schemes[0].Primary = true
+	}
+
+	return schemes
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/elimity-com/scim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceProviderConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    func(auth *config.AuthConfig)
+		schemes []scim.AuthenticationType
+	}{
+		{
+			name: "no authentication",
+			auth: func(auth *config.AuthConfig) {},
+		},
+		{
+			name:    "basic",
+			auth:    func(auth *config.AuthConfig) { auth.Basic.Enabled = true },
+			schemes: []scim.AuthenticationType{scim.AuthenticationTypeHTTPBasic},
+		},
+		{
+			name:    "jwt",
+			auth:    func(auth *config.AuthConfig) { auth.JWT.Enabled = true },
+			schemes: []scim.AuthenticationType{scim.AuthenticationTypeOauthBearerToken},
+		},
+		{
+			name: "credentials",
+			auth: func(auth *config.AuthConfig) {
+				auth.Credentials = []*config.Credential{{Name: "okta", TokenHash: "hash"}, {Name: "hr", Username: "hr"}}
+			},
+			schemes: []scim.AuthenticationType{scim.AuthenticationTypeHTTPBasic, scim.AuthenticationTypeOauthBearerToken},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			cfg := &config.Config{}
+			cfg.Server.MaxResults = 50
+			cfg.Server.Bulk.MaxOperations = 10
+			tc.auth(&cfg.Server.Auth)
+
+			providerConfig := (&SCIMServer{cfg: cfg}).serviceProviderConfig()
+
+			types := make([]scim.AuthenticationType, 0, len(providerConfig.AuthenticationSchemes))
+			for i, scheme := range providerConfig.AuthenticationSchemes {
+				types = append(types, scheme.Type)
+				assert.Equal(i == 0, scheme.Primary)
+			}
+
+			assert.ElementsMatch(tc.schemes, types)
+
+			raw := providerConfig.raw()
+			assert.NotContains(raw, "documentationUri")
+			assert.Equal(map[string]any{"supported": true, "maxResults": 50}, raw["filter"])
+			assert.Equal(true, raw["bulk"].(map[string]any)["supported"])
+		})
+	}
+}
@@ -2,31 +2,47 @@ package app
 
 import (
 	"context"
-	"crypto/sha256"
-	"crypto/subtle"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
 
+	client "github.com/aserto-dev/go-aserto"
 	"github.com/aserto-dev/go-aserto/ds/v3"
 	"github.com/aserto-dev/logger"
+	"github.com/aserto-dev/scim/common/audit"
+	commonconfig "github.com/aserto-dev/scim/common/config"
 	"github.com/aserto-dev/scim/common/convert"
+	"github.com/aserto-dev/scim/common/handlers"
 	"github.com/aserto-dev/scim/common/handlers/groups"
+	"github.com/aserto-dev/scim/common/handlers/resources"
+	"github.com/aserto-dev/scim/common/handlers/roles"
 	"github.com/aserto-dev/scim/common/handlers/users"
 	"github.com/aserto-dev/scim/pkg/app/directory"
 	"github.com/aserto-dev/scim/pkg/config"
 	"github.com/elimity-com/scim"
 	"github.com/elimity-com/scim/optional"
 	"github.com/elimity-com/scim/schema"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
 )
 
 type SCIMServer struct {
-	server   *http.Server
-	log      *zerolog.Logger
-	cfg      *config.Config
-	dsClient *ds.Client
+	server        *http.Server
+	metricsServer *http.Server
+	log           *zerolog.Logger
+	cfg           *config.Config
+	dsClient      *ds.Client
+	metrics       *metrics
+	traces        *sdktrace.TracerProvider
+	audit         audit.Sink
+	redactor      *audit.Redactor
+	tenant        string
+	tenants       []*SCIMServer
 }
 
 func NewSCIMServer(cfgPath string, logWriter logger.Writer, errWriter logger.ErrWriter) (*SCIMServer, error) {
@@ -47,51 +63,54 @@ func NewSCIMServer(cfgPath string, logWriter logger.Writer, errWriter logger.Err
 }
 
 func (s *SCIMServer) Run() error {
-	dsClient, err := directory.GetDirectoryClient(&s.cfg.Directory)
-	if err != nil {
-		return err
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	s.dsClient = dsClient
+	if s.cfg.Tracing.Enabled {
+		traces, err := newTracerProvider(ctx, &s.cfg.Tracing)
+		if err != nil {
+			return err
+		}
 
-	resourceTypes, err := s.resourceTypes()
-	if err != nil {
-		return err
+		tracedLogger := s.log.Hook(traceHook{})
+		s.log = &tracedLogger
+		s.traces = traces
 	}
 
-	serverArgs := &scim.ServerArgs{
-		ServiceProviderConfig: &scim.ServiceProviderConfig{
-			DocumentationURI: optional.NewString("https://aserto.com/docs/scim"),
-			SupportFiltering: true,
-			SupportPatch:     true,
-			AuthenticationSchemes: []scim.AuthenticationScheme{
-				{
-					Type:        scim.AuthenticationTypeHTTPBasic,
-					Name:        "HTTP Basic",
-					Description: "Authentication scheme using the HTTP Basic Standard",
-					SpecURI:     optional.NewString("https://tools.ietf.org/html/rfc7617"),
-				},
-			},
-		},
-		ResourceTypes: resourceTypes,
+	if s.cfg.Metrics.Enabled {
+		s.metrics = newMetrics()
+		s.serveMetrics()
 	}
 
-	server, err := scim.NewServer(serverArgs)
+	if s.cfg.Audit.Enabled {
+		sink, err := newAuditSink(&s.cfg.Audit, s.log)
+		if err != nil {
+			return err
+		}
+
+		s.audit = sink
+		s.redactor = audit.NewRedactor(s.cfg.Audit.Redact...)
+	}
+
+	handler, err := s.rootHandler(ctx)
 	if err != nil {
 		return err
 	}
 
-	app := new(application)
-	app.cfg = &s.cfg.Server.Auth
-
 	tlsServerConfig, err := s.cfg.Server.Certs.ServerConfig()
 	if err != nil {
 		return err
 	}
 
+	if s.cfg.Server.Auth.MTLS.Enabled {
+		if err := withClientCAs(tlsServerConfig, s.cfg.Server.Auth.MTLS.ClientCACertPath); err != nil {
+			return err
+		}
+	}
+
 	srv := &http.Server{
 		Addr:              s.cfg.Server.ListenAddress,
-		Handler:           app.auth(server.ServeHTTP),
+		Handler:           s.withHealth(handler),
 		TLSConfig:         tlsServerConfig,
 		IdleTimeout:       s.cfg.Server.IdleTimeout,
 		ReadTimeout:       s.cfg.Server.ReadTimeout,
@@ -111,10 +130,140 @@ func (s *SCIMServer) Run() error {
 	return srv.ListenAndServe()
 }
 
+// rootHandler returns the SCIM handler of the server or, if tenants are configured, routes requests to the
+// handlers of the tenants.
+func (s *SCIMServer) rootHandler(ctx context.Context) (http.Handler, error) {
+	if len(s.cfg.Tenants) == 0 {
+		return s.scimHandler(ctx)
+	}
+
+	router := newTenantRouter()
+
+	for _, tenant := range s.cfg.Tenants {
+		tenantLogger := s.log.With().Str("tenant", tenant.ID).Logger()
+		tenantServer := &SCIMServer{
+			log:      &tenantLogger,
+			cfg:      s.cfg.Tenant(tenant),
+			metrics:  s.metrics,
+			audit:    s.audit,
+			redactor: s.redactor,
+			tenant:   tenant.ID,
+		}
+		s.tenants = append(s.tenants, tenantServer)
+
+		handler, err := tenantServer.scimHandler(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tenant [%s]", tenant.ID)
+		}
+
+		router.add(tenant, handler)
+		s.log.Info().Str("tenant", tenant.ID).Strs("hosts", tenant.Hosts).Msg("Serving tenant")
+	}
+
+	return router, nil
+}
+
+// scimHandler connects to the directory and returns the authenticated handler of the SCIM endpoints.
+func (s *SCIMServer) scimHandler(ctx context.Context) (http.Handler, error) {
+	var opts []client.ConnectionOption
+	if s.metrics != nil {
+		opts = append(opts, client.WithChainUnaryInterceptor(s.metrics.directoryInterceptor(s.tenant)))
+	}
+
+	if s.cfg.Tracing.Enabled {
+		opts = append(opts, client.WithDialOptions(grpc.WithStatsHandler(otelgrpc.NewClientHandler())))
+	}
+
+	dsClient, err := directory.GetDirectoryClient(&s.cfg.Directory, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.dsClient = dsClient
+
+	resourceTypes, err := s.resourceTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	providerConfig := s.serviceProviderConfig()
+
+	serverArgs := &scim.ServerArgs{
+		ServiceProviderConfig: &providerConfig.ServiceProviderConfig,
+		ResourceTypes:         resourceTypes,
+	}
+
+	server, err := scim.NewServer(serverArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &application{cfg: &s.cfg.Server.Auth, log: s.log, resourceTypes: resourceTypes}
+
+	if s.cfg.Server.Auth.JWT.Enabled {
+		if app.jwt, err = newJWTValidator(ctx, &s.cfg.Server.Auth.JWT); err != nil {
+			return nil, err
+		}
+	}
+
+	var handler http.Handler = app.auth(s.handler(providerConfig, resourceTypes, server))
+	if s.cfg.Tracing.Enabled {
+		handler = traced(resourceTypes, handler)
+	}
+
+	if s.metrics != nil {
+		return s.metrics.instrument(s.tenant, resourceTypes, handler), nil
+	}
+
+	return handler, nil
+}
+
+// serveMetrics starts serving the metrics endpoint on its own listener.
+func (s *SCIMServer) serveMetrics() {
+	s.metricsServer = &http.Server{
+		Addr:              s.cfg.Metrics.ListenAddress,
+		Handler:           s.metrics.handler(),
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+	}
+
+	s.log.Info().Str("address", s.cfg.Metrics.ListenAddress).Msg("Starting metrics server")
+
+	go func() {
+		if err := s.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Err(err).Msg("Metrics server failed")
+		}
+	}()
+}
+
+// handler wraps the SCIM server with the protocol features that the SCIM library does not implement.
+func (s *SCIMServer) handler(
+	providerConfig *serviceProviderConfig,
+	resourceTypes []scim.ResourceType,
+	server scim.Server,
+) http.HandlerFunc {
+	bulk := newBulkHandler(&s.cfg.Server.Bulk, resourceTypes, s.log)
+
+	return withServiceProviderConfig(providerConfig,
+		withBulk(bulk,
+			withIfNoneMatch(
+				withItemsPerPage(resourceEndpoints(resourceTypes), server))))
+}
+
 func (s *SCIMServer) Shutdown(ctx context.Context) error {
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			s.log.Err(err).Msg("Failed to shut down metrics server")
+		}
+
+		s.metricsServer = nil
+	}
+
 	if s.server != nil {
 		s.log.Info().Msg("Shutting down SCIM server")
-		return s.server.Shutdown(ctx)
+
+		if err := s.server.Shutdown(ctx); err != nil {
+			return err
+		}
 	}
 
 	s.server = nil
@@ -128,31 +277,112 @@ func (s *SCIMServer) Shutdown(ctx context.Context) error {
 	}
 
 	s.dsClient = nil
+
+	for _, tenant := range s.tenants {
+		if err := tenant.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.tenants = nil
+
+	if s.traces != nil {
+		if err := s.traces.Shutdown(ctx); err != nil {
+			s.log.Err(err).Msg("Failed to flush traces")
+		}
+	}
+
+	s.traces = nil
+
+	if sink, ok := s.audit.(io.Closer); ok && s.tenant == "" {
+		if err := sink.Close(); err != nil {
+			s.log.Err(err).Msg("Failed to close audit sink")
+		}
+	}
+
+	s.audit = nil
 	s.log.Info().Msg("SCIM server shutdown complete")
 
 	return nil
 }
 
-func (s *SCIMServer) userHandler(cfg *convert.TransformConfig) (scim.ResourceHandler, error) {
+func (s *SCIMServer) userHandler(cfg *convert.TransformConfig, validator *handlers.Validator) (scim.ResourceHandler, error) {
 	usersLogger := s.log.With().Str("component", "users").Logger()
 
-	usersResourceHandler, err := users.NewUsersResourceHandler(&usersLogger, cfg, s.dsClient)
+	usersResourceHandler, err := users.NewUsersResourceHandler(&usersLogger, cfg, s.dsClient, validator)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewResourceHandler(usersResourceHandler)
+	return NewResourceHandler(s.authorize(s.audited(usersResourceHandler, "User"), "User", cfg.User.ObjectType))
 }
 
-func (s *SCIMServer) groupHandler(cfg *convert.TransformConfig) (scim.ResourceHandler, error) {
+func (s *SCIMServer) groupHandler(cfg *convert.TransformConfig, validator *handlers.Validator) (scim.ResourceHandler, error) {
 	groupsLogger := s.log.With().Str("component", "groups").Logger()
 
-	groupsResourceHandler, err := groups.NewGroupResourceHandler(&groupsLogger, cfg, s.dsClient)
+	groupsResourceHandler, err := groups.NewGroupResourceHandler(&groupsLogger, cfg, s.dsClient, validator)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewResourceHandler(groupsResourceHandler)
+	return NewResourceHandler(s.authorize(s.audited(groupsResourceHandler, "Group"), "Group", cfg.Group.ObjectType))
+}
+
+func (s *SCIMServer) roleHandler(cfg *convert.TransformConfig, validator *handlers.Validator) (scim.ResourceHandler, error) {
+	rolesLogger := s.log.With().Str("component", "roles").Logger()
+
+	rolesResourceHandler, err := roles.NewRoleResourceHandler(&rolesLogger, cfg, s.dsClient, validator)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResourceHandler(s.authorize(s.audited(rolesResourceHandler, "Role"), "Role", cfg.Role.ObjectType))
+}
+
+func (s *SCIMServer) resourceHandler(
+	cfg *convert.TransformConfig,
+	resourceType *commonconfig.ResourceType,
+	validator *handlers.Validator,
+) (scim.ResourceHandler, error) {
+	resourceLogger := s.log.With().Str("component", strings.ToLower(resourceType.Name)).Logger()
+
+	resourceHandler, err := resources.NewResourceHandler(&resourceLogger, cfg, resourceType, s.dsClient, validator)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResourceHandler(s.authorize(s.audited(resourceHandler, resourceType.Name), resourceType.Name, resourceType.ObjectType))
+}
+
+// authorize returns the handler of a resource type wrapped in the directory authorization of its operations,
+// if authorization is enabled.
+func (s *SCIMServer) authorize(handler handlers.ResourceHandler, resourceType, objectType string) handlers.ResourceHandler {
+	if !s.cfg.Server.Authorization.Enabled {
+		return handler
+	}
+
+	return authorizedHandler{
+		handler:      handler,
+		authorizer:   newAuthorizer(&s.cfg.Server.Authorization, s.dsClient.Reader, s.log),
+		resourceType: resourceType,
+		objectType:   objectType,
+	}
+}
+
+// audited returns the handler of a resource type wrapped in the audit of its changes, if auditing is enabled.
+func (s *SCIMServer) audited(handler handlers.ResourceHandler, resourceType string) handlers.ResourceHandler {
+	if s.audit == nil {
+		return handler
+	}
+
+	return auditedHandler{
+		handler:      handler,
+		sink:         s.audit,
+		redactor:     s.redactor,
+		tenant:       s.tenant,
+		resourceType: resourceType,
+		log:          s.log,
+	}
 }
 
 func (s *SCIMServer) resourceTypes() ([]scim.ResourceType, error) {
@@ -170,87 +400,87 @@ func (s *SCIMServer) resourceTypes() ([]scim.ResourceType, error) {
 		transformCfg = transformCfg.WithTemplate(templateContent)
 	}
 
-	userHandler, err := s.userHandler(transformCfg)
-	if err != nil {
-		return nil, err
-	}
-
 	userType := scim.ResourceType{
 		ID:          optional.NewString("User"),
 		Name:        "User",
 		Endpoint:    "/Users",
 		Description: optional.NewString("User Account"),
 		Schema:      schema.CoreUserSchema(),
-		SchemaExtensions: []scim.SchemaExtension{
-			{Schema: schema.ExtensionEnterpriseUser()},
-		},
-		Handler: userHandler,
+		SchemaExtensions: append(
+			[]scim.SchemaExtension{{Schema: schema.ExtensionEnterpriseUser()}},
+			schemaExtensions(s.cfg.SCIM.User.SchemaExtensions)...,
+		),
 	}
 
-	groupHandler, err := s.groupHandler(transformCfg)
+	userType.Handler, err = s.userHandler(transformCfg, handlers.NewValidator(userType.Schema, userType.SchemaExtensions...))
 	if err != nil {
 		return nil, err
 	}
 
-	groupType := scim.ResourceType{
-		ID:          optional.NewString("Group"),
-		Name:        "Group",
-		Endpoint:    "/Groups",
-		Description: optional.NewString("Group"),
-		Schema:      schema.CoreGroupSchema(),
-		Handler:     groupHandler,
-	}
+	result := []scim.ResourceType{userType}
 
-	return []scim.ResourceType{userType, groupType}, nil
-}
+	if s.cfg.SCIM.HasGroups() {
+		groupType := scim.ResourceType{
+			ID:               optional.NewString("Group"),
+			Name:             "Group",
+			Endpoint:         "/Groups",
+			Description:      optional.NewString("Group"),
+			Schema:           schema.CoreGroupSchema(),
+			SchemaExtensions: schemaExtensions(s.cfg.SCIM.Group.SchemaExtensions),
+		}
 
-const authzHeaderParts = 2
+		groupType.Handler, err = s.groupHandler(transformCfg, handlers.NewValidator(groupType.Schema, groupType.SchemaExtensions...))
+		if err != nil {
+			return nil, err
+		}
 
-type application struct {
-	cfg *config.AuthConfig
-}
+		result = append(result, groupType)
+	}
 
-func (app *application) auth(next http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !app.cfg.Basic.Enabled && !app.cfg.Bearer.Enabled {
-			next.ServeHTTP(w, r)
-			return
+	// Roles that share the object type and relation of groups can't be told apart from groups, and are only
+	// served as the "roles" attribute of users.
+	switch {
+	case !s.cfg.SCIM.HasRoles():
+	case s.cfg.SCIM.Role.SharesGroupRelation(s.cfg.SCIM.Group):
+		s.log.Warn().Msg("roles share the object type and relation of groups, the roles endpoint is disabled")
+	default:
+		roleType := scim.ResourceType{
+			ID:          optional.NewString("Role"),
+			Name:        "Role",
+			Endpoint:    "/Roles",
+			Description: optional.NewString("Role"),
+			Schema:      roleSchema(),
 		}
 
-		username, password, ok := r.BasicAuth()
-		if ok && app.cfg.Basic.Enabled && app.checkBasicAuth(username, password) {
-			next.ServeHTTP(w, r)
-			return
-		} else if app.cfg.Bearer.Enabled {
-			reqToken := r.Header.Get("Authorization")
-			splitToken := strings.Split(reqToken, "Bearer ")
-
-			if len(splitToken) == authzHeaderParts {
-				if subtle.ConstantTimeCompare([]byte(app.cfg.Bearer.Token), []byte(splitToken[1])) == 1 {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
+		roleType.Handler, err = s.roleHandler(transformCfg, handlers.NewValidator(roleType.Schema))
+		if err != nil {
+			return nil, err
 		}
 
-		w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	})
-}
-
-func (app *application) checkBasicAuth(username, password string) bool {
-	if username == "" || password == "" {
-		return false
+		result = append(result, roleType)
 	}
 
-	usernameHash := sha256.Sum256([]byte(username))
-	passwordHash := sha256.Sum256([]byte(password))
+	for _, resourceType := range s.cfg.SCIM.ResourceTypes {
+		configuredType := scim.ResourceType{
+			ID:               optional.NewString(resourceType.Name),
+			Name:             resourceType.Name,
+			Endpoint:         resourceType.Endpoint,
+			Description:      optionalString(resourceType.Description),
+			Schema:           resourceSchema(resourceType.Schema),
+			SchemaExtensions: schemaExtensions(resourceType.SchemaExtensions),
+		}
 
-	expectedUsernameHash := sha256.Sum256([]byte(app.cfg.Basic.Username))
-	expectedPasswordHash := sha256.Sum256([]byte(app.cfg.Basic.Password))
+		configuredType.Handler, err = s.resourceHandler(
+			transformCfg,
+			resourceType,
+			handlers.NewValidator(configuredType.Schema, configuredType.SchemaExtensions...),
+		)
+		if err != nil {
+			return nil, err
+		}
 
-	usernameMatch := (subtle.ConstantTimeCompare(usernameHash[:], expectedUsernameHash[:]) == 1)
-	passwordMatch := (subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash[:]) == 1)
+		result = append(result, configuredType)
+	}
 
-	return usernameMatch && passwordMatch
+	return result, nil
 }
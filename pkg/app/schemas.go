@@ -0,0 +1,246 @@
+package app
+
+import (
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+// roleSchemaID is the id of the schema of the roles resource type, which SCIM doesn't define.
+const roleSchemaID = "urn:aserto:params:scim:schemas:core:2.0:Role"
+
+// roleSchema returns the schema of roles, modeled after the "roles" attribute of users and the members of groups.
+func roleSchema() schema.Schema {
+	return schema.Schema{
+		ID:          roleSchemaID,
+		Name:        optional.NewString("Role"),
+		Description: optional.NewString("Role"),
+		Attributes: schema.Attributes{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+				Description: optional.NewString("The value of the role, used as its id and in the roles of users. REQUIRED."),
+				Mutability:  schema.AttributeMutabilityImmutable(),
+				Name:        "value",
+				Required:    true,
+				Uniqueness:  schema.AttributeUniquenessServer(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+				Description: optional.NewString("A human-readable name for the role."),
+				Name:        "displayName",
+			})),
+			schema.ComplexCoreAttribute(schema.ComplexParams{
+				Description: optional.NewString("A list of users holding the role."),
+				MultiValued: true,
+				Name:        "members",
+				SubAttributes: []schema.SimpleParams{
+					schema.SimpleStringParams(schema.StringParams{
+						Description: optional.NewString("Identifier of the user."),
+						Mutability:  schema.AttributeMutabilityImmutable(),
+						Name:        "value",
+					}),
+					schema.SimpleReferenceParams(schema.ReferenceParams{
+						Description:    optional.NewString("The URI of the user."),
+						Mutability:     schema.AttributeMutabilityImmutable(),
+						Name:           "$ref",
+						ReferenceTypes: []schema.AttributeReferenceType{"User"},
+					}),
+					schema.SimpleStringParams(schema.StringParams{
+						CanonicalValues: []string{"User"},
+						Description:     optional.NewString("The type of the member."),
+						Mutability:      schema.AttributeMutabilityImmutable(),
+						Name:            "type",
+					}),
+					schema.SimpleStringParams(schema.StringParams{
+						Description: optional.NewString("A human-readable name for the user."),
+						Mutability:  schema.AttributeMutabilityImmutable(),
+						Name:        "display",
+					}),
+				},
+			}),
+		},
+	}
+}
+
+// resourceSchema returns the core schema of a resource type declared in the configuration.
+func resourceSchema(resourceSchema *config.Schema) schema.Schema {
+	attributes := make(schema.Attributes, 0, len(resourceSchema.Attributes))
+
+	for _, attr := range resourceSchema.Attributes {
+		attributes = append(attributes, coreAttribute(attr))
+	}
+
+	return schema.Schema{
+		ID:          resourceSchema.ID,
+		Name:        optionalString(resourceSchema.Name),
+		Description: optionalString(resourceSchema.Description),
+		Attributes:  attributes,
+	}
+}
+
+// schemaExtensions returns the schema extensions of a resource type declared in the configuration.
+func schemaExtensions(extensions []*config.SchemaExtension) []scim.SchemaExtension {
+	result := make([]scim.SchemaExtension, 0, len(extensions))
+
+	for _, extension := range extensions {
+		attributes := make(schema.Attributes, 0, len(extension.Attributes))
+
+		for _, attr := range extension.Attributes {
+			attributes = append(attributes, coreAttribute(attr))
+		}
+
+		result = append(result, scim.SchemaExtension{
+			Schema: schema.Schema{
+				ID:          extension.ID,
+				Name:        optionalString(extension.Name),
+				Description: optionalString(extension.Description),
+				Attributes:  attributes,
+			},
+			Required: extension.Required,
+		})
+	}
+
+	return result
+}
+
+func coreAttribute(attr *config.Attribute) schema.CoreAttribute {
+	if attr.Type != config.AttributeTypeComplex {
+		return schema.SimpleCoreAttribute(simpleParams(attr))
+	}
+
+	subAttributes := make([]schema.SimpleParams, 0, len(attr.SubAttributes))
+	for _, sub := range attr.SubAttributes {
+		subAttributes = append(subAttributes, simpleParams(sub))
+	}
+
+	return schema.ComplexCoreAttribute(schema.ComplexParams{
+		Description:   optionalString(attr.Description),
+		MultiValued:   attr.MultiValued,
+		Mutability:    mutability(attr.Mutability),
+		Name:          attr.Name,
+		Required:      attr.Required,
+		Returned:      returned(attr.Returned),
+		SubAttributes: subAttributes,
+		Uniqueness:    uniqueness(attr.Uniqueness),
+	})
+}
+
+func simpleParams(attr *config.Attribute) schema.SimpleParams {
+	switch attr.Type {
+	case config.AttributeTypeBoolean:
+		return schema.SimpleBooleanParams(schema.BooleanParams{
+			Description: optionalString(attr.Description),
+			MultiValued: attr.MultiValued,
+			Mutability:  mutability(attr.Mutability),
+			Name:        attr.Name,
+			Required:    attr.Required,
+			Returned:    returned(attr.Returned),
+		})
+	case config.AttributeTypeDecimal, config.AttributeTypeInteger:
+		numberType := schema.AttributeTypeDecimal()
+		if attr.Type == config.AttributeTypeInteger {
+			numberType = schema.AttributeTypeInteger()
+		}
+
+		return schema.SimpleNumberParams(schema.NumberParams{
+			Description: optionalString(attr.Description),
+			MultiValued: attr.MultiValued,
+			Mutability:  mutability(attr.Mutability),
+			Name:        attr.Name,
+			Required:    attr.Required,
+			Returned:    returned(attr.Returned),
+			Type:        numberType,
+			Uniqueness:  uniqueness(attr.Uniqueness),
+		})
+	case config.AttributeTypeDateTime:
+		return schema.SimpleDateTimeParams(schema.DateTimeParams{
+			Description: optionalString(attr.Description),
+			MultiValued: attr.MultiValued,
+			Mutability:  mutability(attr.Mutability),
+			Name:        attr.Name,
+			Required:    attr.Required,
+			Returned:    returned(attr.Returned),
+		})
+	case config.AttributeTypeReference:
+		referenceTypes := make([]schema.AttributeReferenceType, 0, len(attr.ReferenceTypes))
+		for _, referenceType := range attr.ReferenceTypes {
+			referenceTypes = append(referenceTypes, schema.AttributeReferenceType(referenceType))
+		}
+
+		return schema.SimpleReferenceParams(schema.ReferenceParams{
+			Description:    optionalString(attr.Description),
+			MultiValued:    attr.MultiValued,
+			Mutability:     mutability(attr.Mutability),
+			Name:           attr.Name,
+			ReferenceTypes: referenceTypes,
+			Required:       attr.Required,
+			Returned:       returned(attr.Returned),
+			Uniqueness:     uniqueness(attr.Uniqueness),
+		})
+	case config.AttributeTypeBinary:
+		return schema.SimpleBinaryParams(schema.BinaryParams{
+			Description: optionalString(attr.Description),
+			MultiValued: attr.MultiValued,
+			Mutability:  mutability(attr.Mutability),
+			Name:        attr.Name,
+			Required:    attr.Required,
+			Returned:    returned(attr.Returned),
+		})
+	default:
+		return schema.SimpleStringParams(schema.StringParams{
+			CanonicalValues: attr.CanonicalValues,
+			CaseExact:       attr.CaseExact,
+			Description:     optionalString(attr.Description),
+			MultiValued:     attr.MultiValued,
+			Mutability:      mutability(attr.Mutability),
+			Name:            attr.Name,
+			Required:        attr.Required,
+			Returned:        returned(attr.Returned),
+			Uniqueness:      uniqueness(attr.Uniqueness),
+		})
+	}
+}
+
+func mutability(value string) schema.AttributeMutability {
+	switch value {
+	case "readOnly":
+		return schema.AttributeMutabilityReadOnly()
+	case "immutable":
+		return schema.AttributeMutabilityImmutable()
+	case "writeOnly":
+		return schema.AttributeMutabilityWriteOnly()
+	default:
+		return schema.AttributeMutabilityReadWrite()
+	}
+}
+
+func returned(value string) schema.AttributeReturned {
+	switch value {
+	case "always":
+		return schema.AttributeReturnedAlways()
+	case "never":
+		return schema.AttributeReturnedNever()
+	case "request":
+		return schema.AttributeReturnedRequest()
+	default:
+		return schema.AttributeReturnedDefault()
+	}
+}
+
+func uniqueness(value string) schema.AttributeUniqueness {
+	switch value {
+	case "server":
+		return schema.AttributeUniquenessServer()
+	case "global":
+		return schema.AttributeUniquenessGlobal()
+	default:
+		return schema.AttributeUniquenessNone()
+	}
+}
+
+func optionalString(value string) optional.String {
+	if value == "" {
+		return optional.String{}
+	}
+
+	return optional.NewString(value)
+}
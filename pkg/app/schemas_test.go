@@ -0,0 +1,106 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/common/handlers"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaExtensions(t *testing.T) {
+	assert := require.New(t)
+
+	const acme = "urn:example:params:scim:schemas:extension:acme:2.0:User"
+
+	extensions := schemaExtensions([]*config.SchemaExtension{
+		{
+			ID:       acme,
+			Name:     "AcmeUser",
+			Required: true,
+			Attributes: []*config.Attribute{
+				{Name: "costCode", Required: true},
+				{Name: "clearance", CanonicalValues: []string{"public", "secret"}},
+				{Name: "level", Type: config.AttributeTypeInteger, Mutability: "immutable"},
+				{Name: "badge", Type: config.AttributeTypeComplex, MultiValued: true, SubAttributes: []*config.Attribute{
+					{Name: "value"},
+					{Name: "issued", Type: config.AttributeTypeDateTime},
+				}},
+			},
+		},
+	})
+
+	assert.Len(extensions, 1)
+	assert.True(extensions[0].Required)
+	assert.Equal("AcmeUser", extensions[0].Schema.Name.Value())
+
+	level, ok := extensions[0].Schema.Attributes.ContainsAttribute("level")
+	assert.True(ok)
+	assert.Equal("integer", level.AttributeType())
+	assert.Equal("immutable", level.Mutability())
+
+	validator := handlers.NewValidator(schema.CoreUserSchema(), extensions...)
+
+	assert.NoError(validator.Validate(scim.ResourceAttributes{
+		"userName": "rick",
+		acme: map[string]any{
+			"costCode":  "C-137",
+			"clearance": "secret",
+			"level":     9,
+			"badge":     []any{map[string]any{"value": "council", "issued": "2017-07-30T00:00:00Z"}},
+		},
+	}))
+	assert.Error(validator.Validate(scim.ResourceAttributes{"userName": "rick"}))
+	assert.Error(validator.Validate(scim.ResourceAttributes{
+		"userName": "rick",
+		acme:       map[string]any{"costCode": "C-137", "clearance": "top"},
+	}))
+}
+
+func TestResourceSchema(t *testing.T) {
+	assert := require.New(t)
+
+	resourceSchema := resourceSchema(&config.Schema{
+		ID:   "urn:example:params:scim:schemas:core:2.0:Device",
+		Name: "Device",
+		Attributes: []*config.Attribute{
+			{Name: "displayName", Required: true},
+			{Name: "serialNumber", Mutability: "immutable", Uniqueness: "server"},
+		},
+	})
+
+	assert.Equal("urn:example:params:scim:schemas:core:2.0:Device", resourceSchema.ID)
+	assert.Equal("Device", resourceSchema.Name.Value())
+
+	validator := handlers.NewValidator(resourceSchema)
+
+	assert.NoError(validator.Validate(scim.ResourceAttributes{"displayName": "portal gun", "serialNumber": "C-137"}))
+	assert.Error(validator.Validate(scim.ResourceAttributes{"serialNumber": "C-137"}))
+	assert.Error(validator.ValidateUpdate(
+		scim.ResourceAttributes{"displayName": "portal gun", "serialNumber": "C-137"},
+		scim.ResourceAttributes{"displayName": "portal gun", "serialNumber": "C-138"},
+	))
+}
+
+func TestRoleSchema(t *testing.T) {
+	assert := require.New(t)
+
+	validator := handlers.NewValidator(roleSchema())
+
+	assert.NoError(validator.Validate(scim.ResourceAttributes{
+		"value":       "admin",
+		"displayName": "Administrator",
+		"members":     []any{map[string]any{"value": "rick", "type": "User"}},
+	}))
+	assert.Error(validator.Validate(scim.ResourceAttributes{"displayName": "Administrator"}))
+	assert.Error(validator.Validate(scim.ResourceAttributes{
+		"value":   "admin",
+		"members": []any{map[string]any{"value": "council", "type": "Group"}},
+	}))
+	assert.Error(validator.ValidateUpdate(
+		scim.ResourceAttributes{"value": "admin"},
+		scim.ResourceAttributes{"value": "superuser"},
+	))
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aserto-dev/scim/pkg/config"
+	serrors "github.com/elimity-com/scim/errors"
+)
+
+const tenantPathPrefix = "/t/"
+
+// tenantRouter routes requests to the tenant named by the /t/{id} prefix of their path, or else to the tenant
+// serving their host.
+type tenantRouter struct {
+	byID   map[string]http.Handler
+	byHost map[string]http.Handler
+}
+
+func newTenantRouter() *tenantRouter {
+	return &tenantRouter{
+		byID:   map[string]http.Handler{},
+		byHost: map[string]http.Handler{},
+	}
+}
+
+func (t *tenantRouter) add(tenant *config.Tenant, handler http.Handler) {
+	t.byID[tenant.ID] = http.StripPrefix(tenantPathPrefix+tenant.ID, handler)
+
+	for _, host := range tenant.Hosts {
+		t.byHost[strings.ToLower(host)] = handler
+	}
+}
+
+func (t *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, tenantPathPrefix); ok {
+		id, _, _ := strings.Cut(path, "/")
+		if handler, ok := t.byID[id]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if handler, ok := t.byHost[strings.ToLower(host)]; ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	writeScimError(w, serrors.ScimError{Detail: "Unknown tenant.", Status: http.StatusNotFound})
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	commonconfig "github.com/aserto-dev/scim/common/config"
+	"github.com/aserto-dev/scim/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantRouter(t *testing.T) {
+	router := newTenantRouter()
+
+	for _, tenant := range []*config.Tenant{
+		{ID: "acme", Hosts: []string{"scim.acme.com"}},
+		{ID: "globex"},
+	} {
+		router.add(tenant, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(tenant.ID + " " + r.URL.Path))
+		}))
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{name: "path", host: "localhost", path: "/t/globex/Users", status: http.StatusOK, body: "globex /Users"},
+		{name: "path takes precedence", host: "scim.acme.com", path: "/t/globex/Groups", status: http.StatusOK, body: "globex /Groups"},
+		{name: "host", host: "SCIM.acme.com:8080", path: "/Users", status: http.StatusOK, body: "acme /Users"},
+		{name: "unknown tenant", host: "localhost", path: "/t/initech/Users", status: http.StatusNotFound},
+		{name: "no tenant", host: "localhost", path: "/Users", status: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, http.NoBody)
+			req.Host = tc.host
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(tc.status, rec.Code)
+
+			if tc.body != "" {
+				assert.Equal(tc.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTenantConfig(t *testing.T) {
+	assert := require.New(t)
+
+	cfg := &config.Config{TemplateFile: "template.tmpl"}
+	cfg.Directory.Address = "directory:9292"
+	cfg.Server.Auth.Bearer.Enabled = true
+	cfg.Server.Auth.MTLS.Enabled = true
+	cfg.SCIM.User = &commonconfig.User{ObjectType: "user"}
+
+	tenant := &config.Tenant{ID: "acme"}
+	tenant.Directory.Address = "acme:9292"
+	tenant.Directory.TenantID = "acme-tenant"
+	tenant.Auth.Basic.Enabled = true
+
+	tenantCfg := cfg.Tenant(tenant)
+
+	assert.Equal("acme:9292", tenantCfg.Directory.Address)
+	assert.Equal("acme-tenant", tenantCfg.Directory.TenantID)
+	assert.True(tenantCfg.Server.Auth.Basic.Enabled)
+	assert.False(tenantCfg.Server.Auth.Bearer.Enabled)
+	assert.True(tenantCfg.Server.Auth.MTLS.Enabled)
+	assert.Equal("user", tenantCfg.SCIM.User.ObjectType)
+	assert.Equal("template.tmpl", tenantCfg.TemplateFile)
+	assert.Equal("directory:9292", cfg.Directory.Address)
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracing(t *testing.T) {
+	assert := require.New(t)
+
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var buf bytes.Buffer
+
+	logger := zerolog.New(&buf).Hook(traceHook{})
+	resourceTypes := []scim.ResourceType{{Name: "User", Endpoint: "/Users"}}
+
+	handler := traced(resourceTypes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Info().Ctx(r.Context()).Msg("patch user")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/Users/rick", http.NoBody))
+
+	ended := spans.Ended()
+	assert.Len(ended, 1)
+	assert.Equal("PATCH User", ended[0].Name())
+
+	var entry map[string]string
+	assert.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(ended[0].SpanContext().TraceID().String(), entry["trace_id"])
+	assert.Equal(ended[0].SpanContext().SpanID().String(), entry["span_id"])
+
+	buf.Reset()
+	logger.Info().Msg("no span")
+	assert.NotContains(buf.String(), "trace_id")
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -19,28 +22,60 @@ const (
 	DefaultReadHeaderTimeout = 2 * time.Second
 	DefaultWriteTimeout      = 10 * time.Second
 	DefaultIdleTimeout       = 30 * time.Second
+	DefaultMaxResults        = 100
+	DefaultBulkMaxOperations = 1000
+	DefaultBulkMaxPayload    = 1048576
+	DefaultJWKSRefresh       = 15 * time.Minute
+	DefaultDocumentationURI  = "https://aserto.com/docs/scim"
+	DefaultMetricsAddress    = ":9090"
+	DefaultTracingEndpoint   = "localhost:4317"
+	DefaultTracingService    = "aserto-scim"
+	DefaultAuditMaxSizeMB    = 100
 )
 
 var (
 	DefaultTLSGenDir = os.ExpandEnv("$HOME/.config/aserto/scim/certs")
 	ErrInvalidConfig = errors.New("invalid config")
+
+	operations = []string{OperationRead, OperationCreate, OperationUpdate, OperationDelete, OperationAll}
+	tokenHash  = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	tenantID   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 )
 
 type Config struct {
 	Logging   logger.Config `json:"logging"`
+	Metrics   MetricsConfig `json:"metrics"`
+	Tracing   TracingConfig `json:"tracing"`
+	Audit     AuditConfig   `json:"audit"`
 	Directory client.Config `json:"directory"`
 	Server    struct {
 		ListenAddress     string           `json:"listen_address"`
 		Certs             client.TLSConfig `json:"certs"`
 		Auth              AuthConfig       `json:"auth"`
+		Authorization     AuthzConfig      `json:"authorization"`
 		ReadTimeout       time.Duration    `json:"read_timeout"`
 		ReadHeaderTimeout time.Duration    `json:"read_header_timeout"`
 		WriteTimeout      time.Duration    `json:"write_timeout"`
 		IdleTimeout       time.Duration    `json:"idle_timeout"`
+		MaxResults        int              `json:"max_results"`
+		Bulk              BulkConfig       `json:"bulk"`
+		DocumentationURI  string           `json:"documentation_uri"`
 	} `json:"server"`
 
 	SCIM         config.Config `json:"scim"`
 	TemplateFile string        `json:"template_file"`
+	Tenants      []*Tenant     `json:"tenants"`
+}
+
+// Tenant is a directory tenant served on the /t/{id} path prefix and on its hosts, with its own directory
+// connection and credentials. SCIM and TemplateFile default to the top-level configuration.
+type Tenant struct {
+	ID           string         `json:"id"`
+	Hosts        []string       `json:"hosts"`
+	Directory    client.Config  `json:"directory"`
+	Auth         AuthConfig     `json:"auth"`
+	SCIM         *config.Config `json:"scim"`
+	TemplateFile string         `json:"template_file"`
 }
 
 type AuthConfig struct {
@@ -53,6 +88,120 @@ type AuthConfig struct {
 		Enabled bool   `json:"enabled"`
 		Token   string `json:"token"`
 	} `json:"bearer"`
+	JWT         JWTConfig     `json:"jwt"`
+	MTLS        MTLSConfig    `json:"mtls"`
+	Credentials []*Credential `json:"credentials"`
+}
+
+// MTLSConfig configures the authentication of callers by TLS client certificates, verified against the CA
+// bundle in ClientCACertPath. Requests without a client certificate can use the other authentication schemes.
+type MTLSConfig struct {
+	Enabled          bool   `json:"enabled"`
+	ClientCACertPath string `json:"client_ca_cert_path"`
+}
+
+// Credential is a named set of basic credentials, bearer token or client certificate subject. Passwords and tokens
+// are stored as hashes. Callers using it are restricted to its permissions.
+type Credential struct {
+	Name               string        `json:"name"`
+	Username           string        `json:"username"`
+	PasswordHash       string        `json:"password_hash"`
+	TokenHash          string        `json:"token_hash"`
+	CertificateSubject string        `json:"certificate_subject"`
+	Permissions        []*Permission `json:"permissions"`
+}
+
+// Permission allows operations on resource types, identified by name such as "User". "*" allows every
+// operation or resource type.
+type Permission struct {
+	ResourceTypes []string `json:"resource_types"`
+	Operations    []string `json:"operations"`
+}
+
+const (
+	OperationRead   = "read"
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationAll    = "*"
+)
+
+// JWTConfig configures the validation of OAuth 2.0 bearer tokens issued as JWTs, such as those sent by Entra ID
+// and Okta. Token signatures are verified with the keys of a JWKS read from a file or fetched from a URL.
+type JWTConfig struct {
+	Enabled         bool          `json:"enabled"`
+	JWKSURL         string        `json:"jwks_url"`
+	JWKSFile        string        `json:"jwks_file"`
+	RefreshInterval time.Duration `json:"refresh_interval"`
+	Issuer          string        `json:"issuer"`
+	Audience        string        `json:"audience"`
+	RequiredScope   string        `json:"required_scope"`
+	AcceptableSkew  time.Duration `json:"acceptable_skew"`
+}
+
+// AuthzConfig configures the authorization of SCIM operations by directory checks. Callers are directory objects
+// of SubjectType identified by their name. Operations on a resource are checked against the directory object of
+// the resource, and creating or listing resources against the object of ResourceTypeObjectType identified by the
+// name of the resource type, such as "User".
+type AuthzConfig struct {
+	Enabled                bool              `json:"enabled"`
+	SubjectType            string            `json:"subject_type"`
+	ResourceTypeObjectType string            `json:"resource_type_object_type"`
+	Permissions            map[string]string `json:"permissions"`
+}
+
+// Permission returns the directory permission checked for an operation.
+func (cfg *AuthzConfig) Permission(operation string) string {
+	if permission, ok := cfg.Permissions[operation]; ok {
+		return permission
+	}
+
+	return "can_" + operation
+}
+
+// MetricsConfig configures the Prometheus metrics endpoint, served on /metrics of its own listener.
+type MetricsConfig struct {
+	Enabled       bool   `json:"enabled"`
+	ListenAddress string `json:"listen_address"`
+}
+
+// TracingConfig configures the export of OpenTelemetry traces to an OTLP gRPC endpoint, such as a local collector.
+// SampleRatio is the fraction of traces sampled when the caller doesn't propagate a sampling decision.
+type TracingConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// AuditConfig configures the audit trail of the changes made by SCIM operations. Entries are written as JSON lines
+// to a rotating file, or to the log. The values of the Redact attributes, and of passwords, are not recorded.
+type AuditConfig struct {
+	Enabled bool            `json:"enabled"`
+	Sink    string          `json:"sink"`
+	File    AuditFileConfig `json:"file"`
+	Redact  []string        `json:"redact"`
+}
+
+const (
+	AuditSinkFile = "file"
+	AuditSinkLog  = "log"
+)
+
+// AuditFileConfig configures the rotation of the audit file. Rotated files are kept for MaxAgeDays, up to
+// MaxBackups files, and are compressed if Compress is set.
+type AuditFileConfig struct {
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxBackups int    `json:"max_backups"`
+	MaxAgeDays int    `json:"max_age_days"`
+	Compress   bool   `json:"compress"`
+}
+
+type BulkConfig struct {
+	MaxOperations  int `json:"max_operations"`
+	MaxPayloadSize int `json:"max_payload_size"`
 }
 
 func NewConfig(configPath string) (*Config, error) {
@@ -80,13 +229,32 @@ func NewConfig(configPath string) (*Config, error) {
 
 	// Set defaults.
 	v.SetDefault("server.listen_address", ":8080")
+	v.SetDefault("metrics.enabled", "false")
+	v.SetDefault("metrics.listen_address", DefaultMetricsAddress)
+	v.SetDefault("tracing.enabled", "false")
+	v.SetDefault("tracing.endpoint", DefaultTracingEndpoint)
+	v.SetDefault("tracing.service_name", DefaultTracingService)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("audit.enabled", "false")
+	v.SetDefault("audit.sink", AuditSinkFile)
+	v.SetDefault("audit.file.max_size_mb", DefaultAuditMaxSizeMB)
 	v.SetDefault("server.auth.basic.enabled", "false")
 	v.SetDefault("server.auth.bearer.enabled", "false")
+	v.SetDefault("server.auth.jwt.enabled", "false")
+	v.SetDefault("server.auth.jwt.refresh_interval", DefaultJWKSRefresh)
+
+	v.SetDefault("server.authorization.enabled", "false")
+	v.SetDefault("server.authorization.subject_type", "scim_client")
+	v.SetDefault("server.authorization.resource_type_object_type", "scim_resource_type")
 
 	v.SetDefault("server.read_timeout", DefaultReadTimeout)
 	v.SetDefault("server.read_header_timeout", DefaultReadHeaderTimeout)
 	v.SetDefault("server.write_timeout", DefaultWriteTimeout)
 	v.SetDefault("server.idle_timeout", DefaultIdleTimeout)
+	v.SetDefault("server.max_results", DefaultMaxResults)
+	v.SetDefault("server.bulk.max_operations", DefaultBulkMaxOperations)
+	v.SetDefault("server.bulk.max_payload_size", DefaultBulkMaxPayload)
+	v.SetDefault("server.documentation_uri", DefaultDocumentationURI)
 
 	v.SetDefault("scim.user.object_type", "user")
 	v.SetDefault("scim.user.identity_object_type", "identity")
@@ -141,7 +309,255 @@ func NewConfig(configPath string) (*Config, error) {
 }
 
 func (cfg *Config) Validate() error {
-	return cfg.SCIM.Validate()
+	if cfg.Server.MaxResults < 1 {
+		return errors.Wrap(ErrInvalidConfig, "server.max_results must be greater than 0")
+	}
+
+	if cfg.Server.Bulk.MaxOperations < 1 {
+		return errors.Wrap(ErrInvalidConfig, "server.bulk.max_operations must be greater than 0")
+	}
+
+	if cfg.Server.Bulk.MaxPayloadSize < 1 {
+		return errors.Wrap(ErrInvalidConfig, "server.bulk.max_payload_size must be greater than 0")
+	}
+
+	if cfg.Metrics.Enabled && cfg.Metrics.ListenAddress == cfg.Server.ListenAddress {
+		return errors.Wrap(ErrInvalidConfig, "metrics.listen_address must differ from server.listen_address")
+	}
+
+	if cfg.Tracing.Enabled && (cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1) {
+		return errors.Wrap(ErrInvalidConfig, "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if err := cfg.Audit.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Server.Auth.JWT.validate("server.auth.jwt"); err != nil {
+		return err
+	}
+
+	if err := cfg.Server.Authorization.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateMTLS(); err != nil {
+		return err
+	}
+
+	if err := validateCredentials("server.auth.credentials", cfg.Server.Auth.Credentials); err != nil {
+		return err
+	}
+
+	if err := cfg.SCIM.Validate(); err != nil {
+		return err
+	}
+
+	return cfg.validateTenants()
+}
+
+func (cfg *JWTConfig) Validate() error {
+	return cfg.validate("server.auth.jwt")
+}
+
+func (cfg *JWTConfig) validate(path string) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if (cfg.JWKSURL == "") == (cfg.JWKSFile == "") {
+		return errors.Wrapf(ErrInvalidConfig, "%s requires exactly one of jwks_url and jwks_file", path)
+	}
+
+	if cfg.Issuer == "" {
+		return errors.Wrapf(ErrInvalidConfig, "%s.issuer is required", path)
+	}
+
+	if cfg.Audience == "" {
+		return errors.Wrapf(ErrInvalidConfig, "%s.audience is required", path)
+	}
+
+	return nil
+}
+
+func (cfg *AuditConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	switch cfg.Sink {
+	case AuditSinkLog:
+	case AuditSinkFile:
+		if cfg.File.Path == "" {
+			return errors.Wrap(ErrInvalidConfig, "audit.file.path is required")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidConfig, "audit.sink: invalid sink [%s]", cfg.Sink)
+	}
+
+	return nil
+}
+
+func (cfg *AuthzConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.SubjectType == "" {
+		return errors.Wrap(ErrInvalidConfig, "server.authorization.subject_type is required")
+	}
+
+	if cfg.ResourceTypeObjectType == "" {
+		return errors.Wrap(ErrInvalidConfig, "server.authorization.resource_type_object_type is required")
+	}
+
+	for operation := range cfg.Permissions {
+		if operation == OperationAll || !slices.Contains(operations, operation) {
+			return errors.Wrapf(ErrInvalidConfig, "server.authorization.permissions: invalid operation [%s]", operation)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *Config) validateMTLS() error {
+	mtls := &cfg.Server.Auth.MTLS
+
+	switch {
+	case !mtls.Enabled:
+		if slices.ContainsFunc(cfg.Server.Auth.Credentials, func(c *Credential) bool { return c.CertificateSubject != "" }) {
+			return errors.Wrap(ErrInvalidConfig, "server.auth.credentials: certificate_subject requires server.auth.mtls")
+		}
+	case !cfg.Server.Certs.HasCert():
+		return errors.Wrap(ErrInvalidConfig, "server.auth.mtls requires server.certs")
+	case mtls.ClientCACertPath == "":
+		return errors.Wrap(ErrInvalidConfig, "server.auth.mtls.client_ca_cert_path is required")
+	}
+
+	return nil
+}
+
+func validateCredentials(path string, credentials []*Credential) error {
+	names := map[string]bool{}
+
+	for _, credential := range credentials {
+		prefix := path + "[" + credential.Name + "]"
+
+		switch {
+		case credential.Name == "":
+			return errors.Wrapf(ErrInvalidConfig, "%s: name is required", path)
+		case names[credential.Name]:
+			return errors.Wrapf(ErrInvalidConfig, "%s: duplicate name [%s]", path, credential.Name)
+		case countNonEmpty(credential.Username, credential.TokenHash, credential.CertificateSubject) != 1:
+			return errors.Wrapf(ErrInvalidConfig, "%s requires exactly one of username, token_hash and certificate_subject", prefix)
+		case credential.Username != "":
+			if _, err := bcrypt.Cost([]byte(credential.PasswordHash)); err != nil {
+				return errors.Wrapf(ErrInvalidConfig, "%s.password_hash must be a bcrypt hash", prefix)
+			}
+		case credential.TokenHash != "" && !tokenHash.MatchString(credential.TokenHash):
+			return errors.Wrapf(ErrInvalidConfig, "%s.token_hash must be a hex encoded sha256 hash", prefix)
+		}
+
+		names[credential.Name] = true
+
+		for _, permission := range credential.Permissions {
+			if len(permission.ResourceTypes) == 0 {
+				return errors.Wrapf(ErrInvalidConfig, "%s.permissions: resource_types is required", prefix)
+			}
+
+			for _, operation := range permission.Operations {
+				if !slices.Contains(operations, operation) {
+					return errors.Wrapf(ErrInvalidConfig, "%s.permissions: invalid operation [%s]", prefix, operation)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cfg *Config) validateTenants() error {
+	ids := map[string]bool{}
+	hosts := map[string]bool{}
+
+	for _, tenant := range cfg.Tenants {
+		prefix := "tenants[" + tenant.ID + "]"
+
+		switch {
+		case !tenantID.MatchString(tenant.ID):
+			return errors.Wrapf(ErrInvalidConfig, "tenants: invalid id [%s]", tenant.ID)
+		case ids[tenant.ID]:
+			return errors.Wrapf(ErrInvalidConfig, "tenants: duplicate id [%s]", tenant.ID)
+		case tenant.Directory.Address == "":
+			return errors.Wrapf(ErrInvalidConfig, "%s.directory.address is required", prefix)
+		case tenant.Auth.MTLS.Enabled:
+			return errors.Wrapf(ErrInvalidConfig, "%s.auth.mtls is not supported, client certificates are configured in server.auth.mtls", prefix)
+		}
+
+		ids[tenant.ID] = true
+
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if hosts[host] {
+				return errors.Wrapf(ErrInvalidConfig, "tenants: duplicate host [%s]", host)
+			}
+
+			hosts[host] = true
+		}
+
+		if err := tenant.Auth.JWT.validate(prefix + ".auth.jwt"); err != nil {
+			return err
+		}
+
+		if err := validateCredentials(prefix+".auth.credentials", tenant.Auth.Credentials); err != nil {
+			return err
+		}
+
+		if !cfg.Server.Auth.MTLS.Enabled &&
+			slices.ContainsFunc(tenant.Auth.Credentials, func(c *Credential) bool { return c.CertificateSubject != "" }) {
+			return errors.Wrapf(ErrInvalidConfig, "%s.auth.credentials: certificate_subject requires server.auth.mtls", prefix)
+		}
+
+		if tenant.SCIM != nil {
+			if err := tenant.SCIM.Validate(); err != nil {
+				return errors.Wrap(err, prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tenant returns the configuration of a tenant: the top-level configuration with the directory, authentication,
+// SCIM and template of the tenant. Client certificates are verified as configured in server.auth.mtls.
+func (cfg *Config) Tenant(tenant *Tenant) *Config {
+	tenantCfg := *cfg
+	tenantCfg.Directory = tenant.Directory
+	tenantCfg.Server.Auth = tenant.Auth
+	tenantCfg.Server.Auth.MTLS = cfg.Server.Auth.MTLS
+	tenantCfg.Tenants = nil
+
+	if tenant.SCIM != nil {
+		tenantCfg.SCIM = *tenant.SCIM
+	}
+
+	if tenant.TemplateFile != "" {
+		tenantCfg.TemplateFile = tenant.TemplateFile
+	}
+
+	return &tenantCfg
+}
+
+func countNonEmpty(values ...string) int {
+	count := 0
+
+	for _, value := range values {
+		if value != "" {
+			count++
+		}
+	}
+
+	return count
 }
 
 func fileExists(path string) (bool, error) {